@@ -0,0 +1,145 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"errors"
+	. "github.com/fishedee/app/log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type AccessLogEntry struct {
+	Time      time.Time
+	Method    string
+	Route     string
+	Protocol  string
+	Host      string
+	Status    int
+	Bytes     int64
+	Duration  time.Duration
+	RemoteIP  string
+	UserAgent string
+	Referer   string
+	RequestId string
+	User      string
+}
+
+type AccessLog interface {
+	Write(entry AccessLogEntry) error
+	Close() error
+}
+
+type AccessLogConfig struct {
+	Format string `config:"format"`
+	File   string `config:"file"`
+}
+
+type accessLogImplement struct {
+	format string
+	log    Log
+	file   *os.File
+	mutex  sync.Mutex
+}
+
+func NewAccessLog(log Log, config AccessLogConfig) (AccessLog, error) {
+	if config.Format == "" {
+		config.Format = "combined"
+	}
+	if config.Format != "combined" && config.Format != "json" {
+		return nil, errors.New("invalid access log format " + config.Format)
+	}
+	result := &accessLogImplement{
+		format: config.Format,
+		log:    log,
+	}
+	if config.File != "" {
+		file, err := os.OpenFile(config.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		result.file = file
+	} else if log == nil {
+		return nil, errors.New("access log need a log or a file")
+	}
+	return result, nil
+}
+
+func getCombinedField(value string) string {
+	//空值用-代替，引号与控制字符转义，保证一行一条
+	if value == "" {
+		return "-"
+	}
+	quote := strconv.Quote(value)
+	return quote[1 : len(quote)-1]
+}
+
+func (this *accessLogImplement) formatCombined(entry AccessLogEntry) string {
+	//Apache Combined格式，请求行使用路由而不是原始url，末尾追加耗时与请求ID
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = strconv.FormatInt(entry.Bytes, 10)
+	}
+	return getCombinedField(entry.RemoteIP) + " - " +
+		getCombinedField(entry.User) + " " +
+		"[" + entry.Time.Format("02/Jan/2006:15:04:05 -0700") + "] " +
+		"\"" + getCombinedField(entry.Method) + " " + getCombinedField(entry.Route) + " " + getCombinedField(entry.Protocol) + "\" " +
+		strconv.Itoa(entry.Status) + " " +
+		bytes + " " +
+		"\"" + getCombinedField(entry.Referer) + "\" " +
+		"\"" + getCombinedField(entry.UserAgent) + "\" " +
+		strconv.FormatFloat(entry.Duration.Seconds(), 'f', 3, 64) + " " +
+		getCombinedField(entry.RequestId)
+}
+
+func (this *accessLogImplement) formatJson(entry AccessLogEntry) (string, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"time":       entry.Time.Format(time.RFC3339Nano),
+		"method":     entry.Method,
+		"route":      entry.Route,
+		"protocol":   entry.Protocol,
+		"host":       entry.Host,
+		"status":     entry.Status,
+		"bytes":      entry.Bytes,
+		"durationMs": float64(entry.Duration) / float64(time.Millisecond),
+		"remoteIp":   entry.RemoteIP,
+		"userAgent":  entry.UserAgent,
+		"referer":    entry.Referer,
+		"requestId":  entry.RequestId,
+		"user":       entry.User,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (this *accessLogImplement) Write(entry AccessLogEntry) error {
+	var line string
+	if this.format == "json" {
+		var err error
+		line, err = this.formatJson(entry)
+		if err != nil {
+			return err
+		}
+	} else {
+		line = this.formatCombined(entry)
+	}
+	if this.file == nil {
+		this.log.Informational("%s", line)
+		return nil
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	_, err := this.file.WriteString(strings.Replace(line, "\n", " ", -1) + "\n")
+	return err
+}
+
+func (this *accessLogImplement) Close() error {
+	if this.file == nil {
+		return nil
+	}
+	return this.file.Close()
+}
@@ -1,12 +1,14 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/astaxie/beego/cache"
 	_ "github.com/astaxie/beego/cache/redis"
 	. "github.com/fishedee/encoding"
 	. "github.com/fishedee/language"
+	"github.com/garyburd/redigo/redis"
 	"reflect"
 	"strings"
 	"sync"
@@ -23,8 +25,13 @@ type Cache interface {
 	Delete(key string) error
 	MustDelete(key string)
 
+	Add(key string, value string, timeout time.Duration) (bool, error)
+	MustAdd(key string, value string, timeout time.Duration) bool
+
 	Memoize(key string, value interface{}, timeout time.Duration) (interface{}, error)
 	MustMemoize(key string, valuer interface{}, timeout time.Duration) interface{}
+
+	WithContext(ctx context.Context) Cache
 }
 
 type CacheConfig struct {
@@ -42,7 +49,9 @@ type cacheHandler struct {
 type cacheImplement struct {
 	store      cache.Cache
 	saveprefix string
-	typeInfo   sync.Map
+	ctx        context.Context
+	typeInfo   *sync.Map
+	add        func(key string, value []byte, timeout time.Duration) (bool, error)
 }
 
 func NewCache(config CacheConfig) (Cache, error) {
@@ -64,6 +73,9 @@ func NewCache(config CacheConfig) (Cache, error) {
 		return &cacheImplement{
 			store:      cacheInner,
 			saveprefix: config.SavePrefix,
+			ctx:        context.Background(),
+			typeInfo:   &sync.Map{},
+			add:        newMemoryAdd(cacheInner),
 		}, nil
 	} else if config.Driver == "redis" {
 		var data struct {
@@ -94,6 +106,9 @@ func NewCache(config CacheConfig) (Cache, error) {
 		return &cacheImplement{
 			store:      cacheInner,
 			saveprefix: config.SavePrefix,
+			ctx:        context.Background(),
+			typeInfo:   &sync.Map{},
+			add:        newRedisAdd(data.Conn, data.Password),
 		}, nil
 	} else {
 		return nil, errors.New("invalid cache config " + config.Driver)
@@ -101,6 +116,9 @@ func NewCache(config CacheConfig) (Cache, error) {
 }
 
 func (this *cacheImplement) getInner(key string) ([]byte, error) {
+	if err := this.ctx.Err(); err != nil {
+		return nil, err
+	}
 	result := this.store.Get(this.saveprefix + key)
 	if result == nil {
 		return nil, nil
@@ -109,6 +127,9 @@ func (this *cacheImplement) getInner(key string) ([]byte, error) {
 }
 
 func (this *cacheImplement) setInner(key string, value []byte, timeout time.Duration) error {
+	if err := this.ctx.Err(); err != nil {
+		return err
+	}
 	err := this.store.Put(this.saveprefix+key, value, timeout)
 	if err != nil {
 		return err
@@ -142,6 +163,9 @@ func (this *cacheImplement) MustSet(key string, value string, timeout time.Durat
 }
 
 func (this *cacheImplement) Delete(key string) error {
+	if err := this.ctx.Err(); err != nil {
+		return err
+	}
 	err := this.store.Delete(this.saveprefix + key)
 	if err != nil && strings.Index(err.Error(), "not exist") == -1 {
 		return err
@@ -156,6 +180,70 @@ func (this *cacheImplement) MustDelete(key string) {
 	}
 }
 
+func newMemoryAdd(store cache.Cache) func(key string, value []byte, timeout time.Duration) (bool, error) {
+	//同一个进程内用锁保证判断与写入是原子的
+	var mutex sync.Mutex
+	return func(key string, value []byte, timeout time.Duration) (bool, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if store.IsExist(key) {
+			return false, nil
+		}
+		err := store.Put(key, value, timeout)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+func newRedisAdd(address string, password string) func(key string, value []byte, timeout time.Duration) (bool, error) {
+	//beego的redis缓存没有SET NX，单独开一个连接池
+	pool := &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			c, err := redis.Dial("tcp", address)
+			if err != nil {
+				return nil, err
+			}
+			if password != "" {
+				if _, err := c.Do("AUTH", password); err != nil {
+					c.Close()
+					return nil, err
+				}
+			}
+			return c, nil
+		},
+	}
+	return func(key string, value []byte, timeout time.Duration) (bool, error) {
+		conn := pool.Get()
+		defer conn.Close()
+
+		result, err := conn.Do("SET", key, value, "PX", int64(timeout/time.Millisecond), "NX")
+		if err != nil {
+			return false, err
+		}
+		return result != nil, nil
+	}
+}
+
+func (this *cacheImplement) Add(key string, value string, timeout time.Duration) (bool, error) {
+	if err := this.ctx.Err(); err != nil {
+		return false, err
+	}
+	return this.add(this.saveprefix+key, []byte(value), timeout)
+}
+
+func (this *cacheImplement) MustAdd(key string, value string, timeout time.Duration) bool {
+	result, err := this.Add(key, value, timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
 func (this *cacheImplement) getHandler(typeInfo reflect.Type) (cacheHandler, error) {
 	handler, isExist := this.typeInfo.Load(typeInfo)
 	if isExist {
@@ -205,6 +293,10 @@ func (this *cacheImplement) Memoize(key string, value interface{}, timeout time.
 		valueCall := reflect.ValueOf(value)
 		callResult := valueCall.Call(nil)
 		getResult := callResult[0].Interface()
+		if err := this.ctx.Err(); err != nil {
+			//context已经取消，计算结果可能不完整，不能写入缓存
+			return nil, err
+		}
 		data, err := handler.encode(getResult)
 		if err != nil {
 			return nil, err
@@ -221,3 +313,14 @@ func (this *cacheImplement) MustMemoize(key string, value interface{}, timeout t
 	}
 	return result
 }
+
+func (this *cacheImplement) WithContext(ctx context.Context) Cache {
+	//底层存储不支持context，每次操作前检查context是否已经取消或者超时
+	return &cacheImplement{
+		store:      this.store,
+		saveprefix: this.saveprefix,
+		ctx:        ctx,
+		typeInfo:   this.typeInfo,
+		add:        this.add,
+	}
+}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"fmt"
 	. "github.com/fishedee/assert"
 	"testing"
@@ -112,3 +113,55 @@ func TestCacheMemoize(t *testing.T) {
 		AssertEqual(t, origin(40), 102334155)
 	}
 }
+
+func TestCacheContext(t *testing.T) {
+	manager := newCacheForTest(t, CacheConfig{
+		Driver:     "memory",
+		SavePrefix: "cache:",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	ctxManager := manager.WithContext(ctx)
+	setData(t, ctxManager, "key1", "value1", time.Minute, 0)
+	AssertEqual(t, getExistData(t, ctxManager, "key1", 0), "value1")
+
+	cancel()
+	_, err := ctxManager.Get("key1")
+	AssertEqual(t, err, context.Canceled)
+	err = ctxManager.Set("key1", "value2", time.Minute)
+	AssertEqual(t, err, context.Canceled)
+	err = ctxManager.Delete("key1")
+	AssertEqual(t, err, context.Canceled)
+	AssertEqual(t, getExistData(t, manager, "key1", 0), "value1")
+
+	//取消以后计算的结果不写入缓存
+	_, err = ctxManager.Memoize("key2", func() int {
+		return 1
+	}, time.Minute)
+	AssertEqual(t, err, context.Canceled)
+	AssertEqual(t, getNoExistData(t, manager, "key2", 0), "")
+}
+
+func TestCacheAdd(t *testing.T) {
+	manager := newCacheForTest(t, CacheConfig{
+		Driver:     "memory",
+		SavePrefix: "cache:",
+	})
+	testCase := []struct {
+		key    string
+		value  string
+		result bool
+	}{
+		{"key1", "value1", true},
+		{"key1", "value2", false},
+		{"key2", "value2", true},
+	}
+	for index, singleTestCase := range testCase {
+		result := manager.MustAdd(singleTestCase.key, singleTestCase.value, time.Minute)
+		AssertEqual(t, result, singleTestCase.result, index)
+	}
+	AssertEqual(t, getExistData(t, manager, "key1", 0), "value1")
+
+	manager.MustDelete("key1")
+	AssertEqual(t, manager.MustAdd("key1", "value3", time.Minute), true)
+	AssertEqual(t, getExistData(t, manager, "key1", 0), "value3")
+}
@@ -0,0 +1,224 @@
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	. "github.com/fishedee/app/session"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+type Csrf interface {
+	IsExempt(path string) bool
+	Token(s Session) (string, error)
+	MustToken(s Session) string
+	ServeHTTP(w http.ResponseWriter, r *http.Request, s Session, next http.HandlerFunc)
+}
+
+type CsrfConfig struct {
+	SessionKey  string   `config:"sessionkey"`
+	FieldName   string   `config:"fieldname"`
+	HeaderName  string   `config:"headername"`
+	Exempt      []string `config:"exempt"`
+	MaxFormSize int      `config:"maxformsize"`
+	MaxFileSize int      `config:"maxfilesize"`
+}
+
+type csrfImplement struct {
+	config CsrfConfig
+}
+
+type csrfContextKey struct{}
+
+type csrfContextValue struct {
+	token     string
+	fieldName string
+}
+
+func WithCsrfToken(ctx context.Context, fieldName string, token string) context.Context {
+	return context.WithValue(ctx, csrfContextKey{}, csrfContextValue{
+		token:     token,
+		fieldName: fieldName,
+	})
+}
+
+func GetCsrfToken(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	value, _ := ctx.Value(csrfContextKey{}).(csrfContextValue)
+	return value.token
+}
+
+func GetCsrfField(ctx context.Context) string {
+	//输出表单的隐藏字段，模板里面直接使用{{csrfField}}
+	if ctx == nil {
+		return ""
+	}
+	value, isOk := ctx.Value(csrfContextKey{}).(csrfContextValue)
+	if isOk == false {
+		return ""
+	}
+	return `<input type="hidden" name="` + template.HTMLEscapeString(value.fieldName) + `" value="` + template.HTMLEscapeString(value.token) + `">`
+}
+
+func NewCsrf(config CsrfConfig) (Csrf, error) {
+	if config.SessionKey == "" {
+		config.SessionKey = "_csrf"
+	}
+	if config.FieldName == "" {
+		config.FieldName = "_csrf"
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	//与ValidatorConfig的默认值一致，表单超过大小时Validator也不会接受
+	if config.MaxFormSize <= 0 {
+		config.MaxFormSize = 1024 * 1024 * 10
+	}
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = 1024 * 1024 * 20
+	}
+	for _, exempt := range config.Exempt {
+		if exempt == "" || exempt[0] != '/' {
+			return nil, errors.New("invalid csrf exempt route " + exempt)
+		}
+	}
+	return &csrfImplement{
+		config: config,
+	}, nil
+}
+
+func (this *csrfImplement) IsExempt(path string) bool {
+	//支付回调与微信推送等外部请求不带token，路由以*结尾时按前缀匹配
+	for _, exempt := range this.config.Exempt {
+		if strings.HasSuffix(exempt, "*") {
+			if strings.HasPrefix(path, exempt[0:len(exempt)-1]) {
+				return true
+			}
+		} else if path == exempt {
+			return true
+		}
+	}
+	return false
+}
+
+func newCsrfToken() (string, error) {
+	data := make([]byte, 32)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+func (this *csrfImplement) Token(s Session) (string, error) {
+	//每个session一个token，session需要已经Begin
+	value, err := s.Get(this.config.SessionKey)
+	if err != nil {
+		return "", err
+	}
+	if token, isOk := value.(string); isOk && token != "" {
+		return token, nil
+	}
+	token, err := newCsrfToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.Set(this.config.SessionKey, token)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (this *csrfImplement) MustToken(s Session) string {
+	token, err := this.Token(s)
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS" || method == "TRACE"
+}
+
+var errCsrfFormTooLarge = errors.New("csrf form too large")
+
+func (this *csrfImplement) getRequestToken(r *http.Request) (string, error) {
+	//优先取头部，ajax请求不需要解析表单
+	token := r.Header.Get(this.config.HeaderName)
+	if token != "" {
+		return token, nil
+	}
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	maxSize := this.config.MaxFormSize
+	if ct == "multipart/form-data" {
+		maxSize = this.config.MaxFileSize
+	} else if ct != "application/x-www-form-urlencoded" {
+		return "", nil
+	}
+	if r.Body == nil {
+		return "", nil
+	}
+
+	//body只能读一次，读出来取token以后放回去，后面的Validator还能解析表单
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxSize {
+		return "", errCsrfFormTooLarge
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	formRequest := r.Clone(r.Context())
+	formRequest.Body = ioutil.NopCloser(bytes.NewReader(data))
+	formRequest.Form = nil
+	formRequest.PostForm = nil
+	formRequest.MultipartForm = nil
+	token = formRequest.PostFormValue(this.config.FieldName)
+	if formRequest.MultipartForm != nil {
+		formRequest.MultipartForm.RemoveAll()
+	}
+	return token, nil
+}
+
+func (this *csrfImplement) ServeHTTP(w http.ResponseWriter, r *http.Request, s Session, next http.HandlerFunc) {
+	err := s.Begin()
+	if err != nil {
+		panic(err)
+	}
+	token, err := this.Token(s)
+	if err != nil {
+		s.Commit()
+		panic(err)
+	}
+	err = s.Commit()
+	if err != nil {
+		panic(err)
+	}
+	if isSafeMethod(r.Method) == false {
+		requestToken, err := this.getRequestToken(r)
+		if err == errCsrfFormTooLarge {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+			http.Error(w, "invalid csrf token", http.StatusForbidden)
+			return
+		}
+	}
+	next(w, r.WithContext(WithCsrfToken(r.Context(), this.config.FieldName, token)))
+}
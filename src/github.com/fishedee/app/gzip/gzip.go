@@ -107,6 +107,7 @@ type gzipResponseWriter struct {
 	hasParse   bool
 	enableGzip bool
 	writer     *gzipWriter
+	started    bool
 	totalSize  int
 	statusCode int
 }
@@ -246,24 +247,15 @@ func (w *gzipResponseWriter) handleGzip(b []byte) error {
 		w.writer = w.gzipImpl.pool.Get().(*gzipWriter)
 	}
 
-	if w.totalSize+len(b) <= w.gzipImpl.minSize {
+	if w.started == false && w.totalSize+len(b) <= w.gzipImpl.minSize {
 		//还未超过minSize
 		copy(w.writer.buffer[w.totalSize:], b)
 	} else {
 		//超过minSize
-		if w.totalSize <= w.gzipImpl.minSize {
-			w.Header().Set(contentEncoding, "gzip")
-			w.Header().Del(contentLength)
-			if w.statusCode != 0 {
-				w.ResponseWriter.WriteHeader(w.statusCode)
-				w.statusCode = 0
-			}
-			w.writer.writer.Reset(w.ResponseWriter)
-			if w.totalSize != 0 {
-				_, err := w.writer.writer.Write(w.writer.buffer[0:w.totalSize])
-				if err != nil {
-					return err
-				}
+		if w.started == false {
+			err := w.startGzip()
+			if err != nil {
+				return err
 			}
 		}
 		_, err := w.writer.writer.Write(b)
@@ -276,6 +268,24 @@ func (w *gzipResponseWriter) handleGzip(b []byte) error {
 	return nil
 }
 
+func (w *gzipResponseWriter) startGzip() error {
+	w.started = true
+	w.Header().Set(contentEncoding, "gzip")
+	w.Header().Del(contentLength)
+	if w.statusCode != 0 {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		w.statusCode = 0
+	}
+	w.writer.writer.Reset(w.ResponseWriter)
+	if w.totalSize != 0 {
+		_, err := w.writer.writer.Write(w.writer.buffer[0:w.totalSize])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (w *gzipResponseWriter) Close() error {
 	if w.statusCode != 0 {
 		w.ResponseWriter.WriteHeader(w.statusCode)
@@ -285,7 +295,7 @@ func (w *gzipResponseWriter) Close() error {
 		return nil
 	}
 
-	if w.totalSize <= w.gzipImpl.minSize {
+	if w.started == false {
 		//还未超过minSize
 		if w.totalSize != 0 {
 			_, err := w.ResponseWriter.Write(w.writer.buffer[0:w.totalSize])
@@ -302,17 +312,22 @@ func (w *gzipResponseWriter) Close() error {
 		}
 	}
 	w.writer = nil
+	w.started = false
 	return nil
 }
 
 func (w *gzipResponseWriter) Flush() {
-	//FIXME 如果数据滞留在buffer里面，则没能及时地flush
-	if w.writer == nil {
-		return
+	//flush时不能再等待minSize，直接开始压缩，把buffer里面的数据与状态码一起推送出去
+	if w.shouldGzip() {
+		if w.writer == nil {
+			w.writer = w.gzipImpl.pool.Get().(*gzipWriter)
+		}
+		if w.started == false && w.startGzip() != nil {
+			return
+		}
+		w.writer.writer.Flush()
 	}
 
-	w.writer.writer.Flush()
-
 	if fw, ok := w.ResponseWriter.(http.Flusher); ok {
 		fw.Flush()
 	}
@@ -242,3 +242,40 @@ func BenchmarkGzip(b *testing.B) {
 		})
 	}
 }
+
+func TestGzipFlush(t *testing.T) {
+	gzip, err := NewGzip(GzipConfig{
+		MinSize: 1024,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	//flush以后不再等待minSize，buffer里面的数据立即压缩输出
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	gzip.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		w.Write([]byte("123"))
+		w.(http.Flusher).Flush()
+		AssertEqual(t, w.Header().Get("Content-Encoding"), "gzip")
+		AssertEqual(t, w.(*gzipResponseWriter).totalSize, 3)
+		w.Write([]byte("456"))
+	})
+	AssertEqual(t, w.Code, 201)
+	AssertEqual(t, w.Flushed, true)
+	data, err := DecompressGzip(w.Body.Bytes())
+	AssertEqual(t, err, nil)
+	AssertEqual(t, string(data), "123456")
+
+	//不压缩时直接flush
+	r2, _ := http.NewRequest("GET", "/", nil)
+	w2 := httptest.NewRecorder()
+	gzip.ServeHTTP(w2, r2, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("123"))
+		w.(http.Flusher).Flush()
+	})
+	AssertEqual(t, w2.Flushed, true)
+	AssertEqual(t, w2.Body.String(), "123")
+}
@@ -0,0 +1,330 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	. "github.com/fishedee/app/cache"
+	. "github.com/fishedee/app/session"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type HttpCacheRule struct {
+	Timeout time.Duration
+	Query   []string
+	Session []string
+	Vary    []string
+	Tag     []string
+}
+
+type HttpCache interface {
+	Purge(ctx context.Context, tag ...string) error
+	MustPurge(ctx context.Context, tag ...string)
+	ServeHTTP(w http.ResponseWriter, r *http.Request, s Session, rule HttpCacheRule, next http.HandlerFunc)
+}
+
+type HttpCacheConfig struct {
+	SavePrefix string        `config:"saveprefix"`
+	TagTimeout time.Duration `config:"tagtimeout"`
+}
+
+const httpCacheControl = "private, no-cache"
+
+type httpCacheImplement struct {
+	cache  Cache
+	config HttpCacheConfig
+}
+
+type HttpCacheResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func (this *HttpCacheResponse) Replay(w http.ResponseWriter, r *http.Request, name string, value string) {
+	header := w.Header()
+	for key, value := range this.Header {
+		header[key] = value
+	}
+	header.Set(name, value)
+	w.WriteHeader(this.Status)
+	if r.Method != "HEAD" {
+		w.Write(this.Body)
+	}
+}
+
+type HttpCacheWriter struct {
+	writer      http.ResponseWriter
+	header      http.Header
+	status      int
+	wroteHeader bool
+	buffer      bytes.Buffer
+}
+
+func NewHttpCacheWriter(w http.ResponseWriter) *HttpCacheWriter {
+	return &HttpCacheWriter{
+		writer: w,
+	}
+}
+
+func (this *HttpCacheWriter) Header() http.Header {
+	return this.writer.Header()
+}
+
+func (this *HttpCacheWriter) WriteHeader(status int) {
+	if this.wroteHeader {
+		return
+	}
+	this.wroteHeader = true
+	this.status = status
+	this.header = http.Header{}
+	for key, value := range this.writer.Header() {
+		this.header[key] = value
+	}
+	this.writer.WriteHeader(status)
+}
+
+func (this *HttpCacheWriter) Write(data []byte) (int, error) {
+	if this.wroteHeader == false {
+		this.WriteHeader(http.StatusOK)
+	}
+	this.buffer.Write(data)
+	return this.writer.Write(data)
+}
+
+func (this *HttpCacheWriter) Response() HttpCacheResponse {
+	//头部是WriteHeader时的快照，之后handler对头部的修改不会发送给客户端
+	if this.wroteHeader == false {
+		this.WriteHeader(http.StatusOK)
+	}
+	header := http.Header{}
+	for key, value := range this.header {
+		header[key] = value
+	}
+	return HttpCacheResponse{
+		Status: this.status,
+		Header: header,
+		Body:   this.buffer.Bytes(),
+	}
+}
+
+func NewHttpCache(cache Cache, config HttpCacheConfig) (HttpCache, error) {
+	if config.SavePrefix == "" {
+		config.SavePrefix = "httpcache:"
+	}
+	if config.TagTimeout <= 0 {
+		config.TagTimeout = 30 * 24 * time.Hour
+	}
+	return &httpCacheImplement{
+		cache:  cache,
+		config: config,
+	}, nil
+}
+
+func (this *httpCacheImplement) getTagKey(tag string) string {
+	return this.config.SavePrefix + "tag:" + tag
+}
+
+func (this *httpCacheImplement) Purge(ctx context.Context, tag ...string) error {
+	//缓存不支持按前缀删除，更新标签的版本号让旧的缓存全部失效
+	cache := this.cache.WithContext(ctx)
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for _, singleTag := range tag {
+		err := cache.Set(this.getTagKey(singleTag), version, this.config.TagTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *httpCacheImplement) MustPurge(ctx context.Context, tag ...string) {
+	err := this.Purge(ctx, tag...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func getCacheControl(header string) map[string]string {
+	result := map[string]string{}
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		index := strings.IndexByte(directive, '=')
+		if index == -1 {
+			result[strings.ToLower(directive)] = ""
+		} else {
+			result[strings.ToLower(directive[0:index])] = strings.Trim(directive[index+1:], "\"")
+		}
+	}
+	return result
+}
+
+func (this *httpCacheImplement) getKey(cache Cache, r *http.Request, s Session, rule HttpCacheRule) (string, error) {
+	//键由方法，路径，选中的查询参数，session字段，Vary头部与标签版本组成
+	var buffer bytes.Buffer
+	buffer.WriteString(r.Method + "\n" + r.URL.Path + "\n")
+	query := r.URL.Query()
+	if rule.Query == nil {
+		buffer.WriteString(query.Encode())
+	} else {
+		selectQuery := url.Values{}
+		for _, name := range rule.Query {
+			if value, isExist := query[name]; isExist {
+				selectQuery[name] = value
+			}
+		}
+		buffer.WriteString(selectQuery.Encode())
+	}
+	buffer.WriteString("\n")
+	if len(rule.Session) != 0 {
+		err := s.Begin()
+		if err != nil {
+			return "", err
+		}
+		for _, name := range rule.Session {
+			value, err := s.Get(name)
+			if err != nil {
+				s.Commit()
+				return "", err
+			}
+			buffer.WriteString(name + "=" + fmt.Sprintf("%v", value) + "\n")
+		}
+		err = s.Commit()
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, name := range rule.Vary {
+		buffer.WriteString(name + "=" + r.Header.Get(name) + "\n")
+	}
+	for _, tag := range rule.Tag {
+		version, err := cache.Get(this.getTagKey(tag))
+		if err != nil {
+			return "", err
+		}
+		buffer.WriteString(tag + "=" + version + "\n")
+	}
+	hash := sha1.Sum(buffer.Bytes())
+	return this.config.SavePrefix + hex.EncodeToString(hash[:]), nil
+}
+
+func (this *httpCacheImplement) serveCache(w http.ResponseWriter, r *http.Request, data string) bool {
+	var response HttpCacheResponse
+	err := json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return false
+	}
+	response.Replay(w, r, "X-Cache", "HIT")
+	return true
+}
+
+func getVaryName(header http.Header) map[string]bool {
+	result := map[string]bool{}
+	for _, value := range header["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				result[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+	return result
+}
+
+func (this *httpCacheImplement) getTimeout(header http.Header, cookieCount int, varyName map[string]bool, rule HttpCacheRule) time.Duration {
+	//响应的Cache-Control优先，private与no-store的响应，以及handler设置了cookie的响应不缓存
+	if len(header["Set-Cookie"]) != cookieCount {
+		return 0
+	}
+	//handler按缓存键以外的请求头输出不同的内容时不缓存，例如auto的Accept与gzip的Accept-Encoding
+	for name := range getVaryName(header) {
+		if varyName[name] == false {
+			return 0
+		}
+	}
+	//Cache-Control仍然是预设的值时，按路由的规则缓存
+	if len(header["Cache-Control"]) == 1 && header.Get("Cache-Control") == httpCacheControl {
+		return rule.Timeout
+	}
+	cacheControl := getCacheControl(header.Get("Cache-Control"))
+	if _, isExist := cacheControl["no-store"]; isExist {
+		return 0
+	}
+	if _, isExist := cacheControl["private"]; isExist {
+		return 0
+	}
+	if _, isExist := cacheControl["no-cache"]; isExist {
+		return 0
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if maxAge, isExist := cacheControl[name]; isExist {
+			second, err := strconv.Atoi(maxAge)
+			if err == nil {
+				return time.Duration(second) * time.Second
+			}
+		}
+	}
+	return rule.Timeout
+}
+
+func (this *httpCacheImplement) ServeHTTP(w http.ResponseWriter, r *http.Request, s Session, rule HttpCacheRule, next http.HandlerFunc) {
+	if (r.Method != "GET" && r.Method != "HEAD") || rule.Timeout <= 0 {
+		next(w, r)
+		return
+	}
+	requestCacheControl := getCacheControl(r.Header.Get("Cache-Control"))
+	if _, isExist := requestCacheControl["no-store"]; isExist {
+		next(w, r)
+		return
+	}
+	cache := this.cache.WithContext(r.Context())
+	key, err := this.getKey(cache, r, s, rule)
+	if err != nil {
+		//缓存不可用时直接执行handler
+		next(w, r)
+		return
+	}
+	if _, isExist := requestCacheControl["no-cache"]; isExist == false {
+		data, err := cache.Get(key)
+		if err == nil && data != "" && this.serveCache(w, r, data) {
+			return
+		}
+	}
+
+	if len(rule.Vary) != 0 {
+		w.Header().Add("Vary", strings.Join(rule.Vary, ", "))
+	}
+	varyName := getVaryName(w.Header())
+	//预先设置Cache-Control，客户端每次都要验证，格式化器不再覆盖为no-store，服务端按规则缓存
+	w.Header().Set("Cache-Control", httpCacheControl)
+	w.Header().Set("X-Cache", "MISS")
+	//session续期的cookie在handler之前已经设置，不影响缓存
+	cookieCount := len(w.Header()["Set-Cookie"])
+	cacheWriter := NewHttpCacheWriter(w)
+	next(cacheWriter, r)
+	response := cacheWriter.Response()
+	if response.Status != http.StatusOK || r.Method == "HEAD" {
+		return
+	}
+	timeout := this.getTimeout(response.Header, cookieCount, varyName, rule)
+	if timeout <= 0 {
+		return
+	}
+	response.Header.Del("X-Cache")
+	response.Header.Del("Set-Cookie")
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	cache.Set(key, string(data), timeout)
+}
@@ -0,0 +1,169 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	. "github.com/fishedee/app/cache"
+	. "github.com/fishedee/app/httpcache"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type Idempotency interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, path string, client string, next http.HandlerFunc)
+}
+
+type IdempotencyConfig struct {
+	Header      string        `config:"header"`
+	SavePrefix  string        `config:"saveprefix"`
+	Timeout     time.Duration `config:"timeout"`
+	LockTimeout time.Duration `config:"locktimeout"`
+	MaxBodySize int           `config:"maxbodysize"`
+}
+
+type idempotencyImplement struct {
+	cache  Cache
+	config IdempotencyConfig
+}
+
+type idempotencyResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	HttpCacheResponse
+}
+
+func NewIdempotency(cache Cache, config IdempotencyConfig) (Idempotency, error) {
+	if config.Header == "" {
+		config.Header = "Idempotency-Key"
+	}
+	if config.SavePrefix == "" {
+		config.SavePrefix = "idempotency:"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 24 * time.Hour
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = time.Minute
+	}
+	//与ValidatorConfig的MaxFileSize默认值一致
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1024 * 1024 * 20
+	}
+	return &idempotencyImplement{
+		cache:  cache,
+		config: config,
+	}, nil
+}
+
+var errIdempotencyBodyTooLarge = errors.New("idempotency body too large")
+
+func (this *idempotencyImplement) getFingerprint(r *http.Request) (string, error) {
+	//body只能读一次，读出来计算指纹以后放回去给handler
+	var data []byte
+	if r.Body != nil {
+		var err error
+		data, err = ioutil.ReadAll(io.LimitReader(r.Body, int64(this.config.MaxBodySize)+1))
+		if err != nil {
+			return "", err
+		}
+		if len(data) > this.config.MaxBodySize {
+			return "", errIdempotencyBodyTooLarge
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+	hash := sha1.New()
+	hash.Write([]byte(r.URL.RawQuery + "\n" + r.Header.Get("Content-Type") + "\n"))
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (this *idempotencyImplement) replay(w http.ResponseWriter, r *http.Request, data string, fingerprint string) bool {
+	var response idempotencyResponse
+	err := json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return false
+	}
+	if response.Fingerprint != fingerprint {
+		http.Error(w, this.config.Header+" is already used with a different request", http.StatusUnprocessableEntity)
+		return true
+	}
+	response.Replay(w, r, "Idempotent-Replayed", "true")
+	return true
+}
+
+func (this *idempotencyImplement) ServeHTTP(w http.ResponseWriter, r *http.Request, path string, client string, next http.HandlerFunc) {
+	if r.Method != "POST" && r.Method != "PUT" && r.Method != "PATCH" {
+		next(w, r)
+		return
+	}
+	requestKey := r.Header.Get(this.config.Header)
+	if requestKey == "" {
+		next(w, r)
+		return
+	}
+	if len(requestKey) > 255 {
+		http.Error(w, "invalid "+this.config.Header, http.StatusBadRequest)
+		return
+	}
+	fingerprint, err := this.getFingerprint(r)
+	if err == errIdempotencyBodyTooLarge {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	//同一个key在不同的路由与不同的客户端上互不影响
+	hash := sha1.Sum([]byte(r.Method + "\n" + path + "\n" + client + "\n" + requestKey))
+	key := this.config.SavePrefix + hex.EncodeToString(hash[:])
+	lockKey := key + ":lock"
+	cache := this.cache.WithContext(r.Context())
+	data, err := cache.Get(key)
+	if err == nil && data != "" && this.replay(w, r, data, fingerprint) {
+		return
+	}
+	isLock, err := cache.Add(lockKey, fingerprint, this.config.LockTimeout)
+	if err != nil {
+		//缓存不可用时直接执行handler，与httpcache一致
+		next(w, r)
+		return
+	}
+	if isLock == false {
+		lockFingerprint, err := cache.Get(lockKey)
+		if err == nil && lockFingerprint != "" && lockFingerprint != fingerprint {
+			http.Error(w, this.config.Header+" is already used with a different request", http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "request with the same "+this.config.Header+" is in progress", http.StatusConflict)
+		return
+	}
+	//请求的context取消以后也要释放锁与保存结果，所以不使用cache.WithContext
+	defer this.cache.Delete(lockKey)
+
+	//拿到锁之前第一个请求可能刚好完成
+	data, err = cache.Get(key)
+	if err == nil && data != "" && this.replay(w, r, data, fingerprint) {
+		return
+	}
+	writer := NewHttpCacheWriter(w)
+	next(writer, r)
+	response := writer.Response()
+	if response.Status >= 500 {
+		//服务端错误不保存，客户端可以用同一个key重试
+		return
+	}
+	response.Header.Del("Set-Cookie")
+	result, err := json.Marshal(idempotencyResponse{
+		Fingerprint:       fingerprint,
+		HttpCacheResponse: response,
+	})
+	if err != nil {
+		return
+	}
+	this.cache.Set(key, string(result), this.config.Timeout)
+}
@@ -0,0 +1,131 @@
+package loadshed
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type limiterOption struct {
+	maxQueue       int
+	adaptive       bool
+	minConcurrency int
+	maxConcurrency int
+}
+
+type limiter struct {
+	mutex    sync.Mutex
+	option   limiterOption
+	limit    float64
+	inflight int
+	waiter   []chan struct{}
+	longRtt  float64
+}
+
+func newLimiter(limit int, option limiterOption) *limiter {
+	return &limiter{
+		option: option,
+		limit:  float64(limit),
+	}
+}
+
+func (this *limiter) getLimit() int {
+	return int(this.limit)
+}
+
+func (this *limiter) Limit() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.getLimit()
+}
+
+func (this *limiter) Inflight() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.inflight
+}
+
+func (this *limiter) Acquire(ctx context.Context, timeout time.Duration) bool {
+	this.mutex.Lock()
+	if this.inflight < this.getLimit() && len(this.waiter) == 0 {
+		this.inflight++
+		this.mutex.Unlock()
+		return true
+	}
+	if timeout <= 0 || len(this.waiter) >= this.option.maxQueue {
+		this.mutex.Unlock()
+		return false
+	}
+	wait := make(chan struct{})
+	this.waiter = append(this.waiter, wait)
+	this.mutex.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-wait:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	//超时以后从队列里面删除，如果刚好被唤醒了就当作拿到了
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for index, single := range this.waiter {
+		if single == wait {
+			this.waiter = append(this.waiter[0:index], this.waiter[index+1:]...)
+			return false
+		}
+	}
+	return true
+}
+
+func (this *limiter) wakeup() {
+	//按先进先出唤醒排队的请求，名额直接转交
+	for len(this.waiter) != 0 && this.inflight < this.getLimit() {
+		wait := this.waiter[0]
+		this.waiter = this.waiter[1:]
+		this.inflight++
+		close(wait)
+	}
+}
+
+func (this *limiter) Release(rtt time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	inflight := this.inflight
+	this.inflight--
+	if this.option.adaptive {
+		this.update(float64(rtt), inflight)
+	}
+	this.wakeup()
+}
+
+func (this *limiter) update(rtt float64, inflight int) {
+	//参考concurrency-limits的Gradient2，长期的平均延迟与当前延迟的比值决定并发数的增减
+	if rtt <= 0 {
+		return
+	}
+	if this.longRtt == 0 {
+		this.longRtt = rtt
+	} else {
+		this.longRtt = this.longRtt*0.99 + rtt*0.01
+	}
+	//当前延迟远低于平均延迟时，平均延迟逐渐回落
+	if this.longRtt/rtt > 2 {
+		this.longRtt = this.longRtt * 0.95
+	}
+	//请求太少时延迟没有参考意义，不扩大并发数
+	if float64(inflight) < this.limit/2 {
+		return
+	}
+	gradient := math.Max(0.5, math.Min(1.0, this.longRtt/rtt))
+	queueSize := math.Sqrt(this.limit)
+	newLimit := this.limit*gradient + queueSize
+	newLimit = this.limit*0.8 + newLimit*0.2
+	newLimit = math.Max(float64(this.option.minConcurrency), math.Min(float64(this.option.maxConcurrency), newLimit))
+	this.limit = newLimit
+}
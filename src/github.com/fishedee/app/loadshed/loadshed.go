@@ -0,0 +1,208 @@
+package loadshed
+
+import (
+	"errors"
+	. "github.com/fishedee/app/metric"
+	. "github.com/fishedee/encoding"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LoadShed interface {
+	Limit(path string) int
+	ServeHTTP(w http.ResponseWriter, r *http.Request, host string, path string, limit int, next http.HandlerFunc)
+}
+
+type LoadShedConfig struct {
+	MaxConcurrency int           `config:"maxconcurrency"`
+	MinConcurrency int           `config:"minconcurrency"`
+	Adaptive       bool          `config:"adaptive"`
+	MaxQueue       int           `config:"maxqueue"`
+	QueueTimeout   time.Duration `config:"queuetimeout"`
+	Route          []string      `config:"route"`
+}
+
+type loadShedRoute struct {
+	limit   int
+	limiter *limiter
+	shed    MetricCounter
+	current MetricGauge
+}
+
+type loadShedImplement struct {
+	config     LoadShedConfig
+	metric     Metric
+	global     *limiter
+	shed       MetricCounter
+	current    MetricGauge
+	limit      MetricGauge
+	routeLimit map[string]int
+	route      map[string]*loadShedRoute
+	routeMutex sync.Mutex
+}
+
+func NewLoadShed(metric Metric, config LoadShedConfig) (LoadShed, error) {
+	if config.MaxConcurrency < 0 || config.MinConcurrency < 0 || config.MaxQueue < 0 {
+		return nil, errors.New("invalid load shed config")
+	}
+	if config.MinConcurrency == 0 {
+		config.MinConcurrency = 1
+	}
+	if config.Adaptive && config.MaxConcurrency == 0 {
+		return nil, errors.New("adaptive load shed need max concurrency")
+	}
+	if config.QueueTimeout == 0 {
+		config.QueueTimeout = 100 * time.Millisecond
+	}
+	routeLimit := map[string]int{}
+	for _, route := range config.Route {
+		//路由的格式为：/report/export 5
+		fields := strings.Fields(route)
+		if len(fields) != 2 {
+			return nil, errors.New("invalid load shed route " + route)
+		}
+		limit, err := strconv.Atoi(fields[1])
+		if err != nil || limit < 0 {
+			return nil, errors.New("invalid load shed route " + route)
+		}
+		routeLimit[fields[0]] = limit
+	}
+	result := &loadShedImplement{
+		config:     config,
+		metric:     metric,
+		routeLimit: routeLimit,
+		route:      map[string]*loadShedRoute{},
+	}
+	if config.MaxConcurrency != 0 {
+		result.global = newLimiter(config.MaxConcurrency, limiterOption{
+			maxQueue:       getMaxQueue(config.MaxQueue, config.MaxConcurrency),
+			adaptive:       config.Adaptive,
+			minConcurrency: config.MinConcurrency,
+			maxConcurrency: config.MaxConcurrency,
+		})
+	}
+	if metric != nil {
+		result.shed = metric.GetCounter("loadshed.shed")
+		result.current = metric.GetGauge("loadshed.concurrency")
+		result.limit = metric.GetGauge("loadshed.limit")
+	}
+	return result, nil
+}
+
+func getMaxQueue(maxQueue int, limit int) int {
+	if maxQueue == 0 {
+		return limit
+	}
+	return maxQueue
+}
+
+func (this *loadShedImplement) Limit(path string) int {
+	return this.routeLimit[path]
+}
+
+func (this *loadShedImplement) getRoute(method string, host string, path string, limit int) *loadShedRoute {
+	this.routeMutex.Lock()
+	defer this.routeMutex.Unlock()
+
+	//同一个路径的不同方法与不同域名分组是不同的路由，各自限制
+	key := method + " " + host + " " + path
+	route, isExist := this.route[key]
+	if isExist && route.limit == limit {
+		return route
+	}
+	//路由更新以后限制变了，换成新的limiter，执行中的请求释放旧的limiter的名额
+	route = &loadShedRoute{
+		limit: limit,
+		limiter: newLimiter(limit, limiterOption{
+			maxQueue: getMaxQueue(this.config.MaxQueue, limit),
+		}),
+	}
+	if this.metric != nil {
+		tag, err := EncodeUrl(path)
+		if err != nil {
+			panic(err)
+		}
+		tag = "method=" + method + "&path=" + tag
+		if host != "" {
+			hostEncoding, err := EncodeUrl(host)
+			if err != nil {
+				panic(err)
+			}
+			tag += "&host=" + hostEncoding
+		}
+		route.shed = this.metric.GetCounter("loadshed.shed?" + tag)
+		route.current = this.metric.GetGauge("loadshed.concurrency?" + tag)
+	}
+	this.route[key] = route
+	return route
+}
+
+func (this *loadShedImplement) reportGlobal() {
+	if this.current == nil || this.global == nil {
+		return
+	}
+	this.current.Update(int64(this.global.Inflight()))
+	this.limit.Update(int64(this.global.Limit()))
+}
+
+func (this *loadShedImplement) reject(w http.ResponseWriter, route *loadShedRoute) {
+	if this.shed != nil {
+		this.shed.Inc(1)
+	}
+	if route != nil && route.shed != nil {
+		route.shed.Inc(1)
+	}
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+func (this *loadShedImplement) ServeHTTP(w http.ResponseWriter, r *http.Request, host string, path string, limit int, next http.HandlerFunc) {
+	//先拿全局的名额，再拿路由的名额，两者共用排队的时间
+	deadline := time.Now().Add(this.config.QueueTimeout)
+	var route *loadShedRoute
+	if limit > 0 {
+		route = this.getRoute(r.Method, host, path, limit)
+	}
+	if this.global != nil {
+		if this.global.Acquire(r.Context(), this.config.QueueTimeout) == false {
+			this.reject(w, route)
+			return
+		}
+	}
+	var beginTime time.Time
+	isRun := false
+	defer func() {
+		if this.global != nil {
+			//被路由拒绝的请求没有执行，不参与延迟的统计
+			var rtt time.Duration
+			if isRun {
+				rtt = time.Since(beginTime)
+			}
+			this.global.Release(rtt)
+			this.reportGlobal()
+		}
+	}()
+	this.reportGlobal()
+
+	if route != nil {
+		if route.limiter.Acquire(r.Context(), time.Until(deadline)) == false {
+			this.reject(w, route)
+			return
+		}
+		defer func() {
+			route.limiter.Release(0)
+			if route.current != nil {
+				route.current.Update(int64(route.limiter.Inflight()))
+			}
+		}()
+		if route.current != nil {
+			route.current.Update(int64(route.limiter.Inflight()))
+		}
+	}
+	isRun = true
+	beginTime = time.Now()
+	next(w, r)
+}
@@ -0,0 +1,71 @@
+package loadshed
+
+import (
+	"context"
+	. "github.com/fishedee/assert"
+	"testing"
+	"time"
+)
+
+func TestLimiterQueue(t *testing.T) {
+	limiter := newLimiter(1, limiterOption{maxQueue: 1})
+	ctx := context.Background()
+	AssertEqual(t, limiter.Acquire(ctx, 0), true)
+	AssertEqual(t, limiter.Acquire(ctx, 0), false)
+	AssertEqual(t, limiter.Acquire(ctx, 10*time.Millisecond), false)
+
+	//排队的请求在释放以后拿到名额，队列满了直接拒绝
+	done := make(chan bool)
+	go func() {
+		done <- limiter.Acquire(ctx, time.Second)
+	}()
+	for {
+		limiter.mutex.Lock()
+		waiter := len(limiter.waiter)
+		limiter.mutex.Unlock()
+		if waiter == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	AssertEqual(t, limiter.Acquire(ctx, time.Second), false)
+	limiter.Release(0)
+	AssertEqual(t, <-done, true)
+	AssertEqual(t, limiter.Inflight(), 1)
+	limiter.Release(0)
+	AssertEqual(t, limiter.Inflight(), 0)
+}
+
+func TestLimiterAdaptive(t *testing.T) {
+	limiter := newLimiter(100, limiterOption{
+		maxQueue:       100,
+		adaptive:       true,
+		minConcurrency: 10,
+		maxConcurrency: 100,
+	})
+	ctx := context.Background()
+	run := func(count int, rtt time.Duration) {
+		for i := 0; i != count; i++ {
+			limiter.Acquire(ctx, 0)
+		}
+		for i := 0; i != count; i++ {
+			limiter.Release(rtt)
+		}
+	}
+
+	//延迟稳定时保持最大并发数
+	run(100, 10*time.Millisecond)
+	AssertEqual(t, limiter.Limit(), 100)
+
+	//延迟突然变大时并发数下降，但是不低于最小值
+	run(limiter.Limit(), 100*time.Millisecond)
+	AssertEqual(t, limiter.Limit() < 100, true)
+	AssertEqual(t, limiter.Limit() >= 10, true)
+
+	//延迟恢复以后并发数回升
+	lowLimit := limiter.Limit()
+	for i := 0; i != 10; i++ {
+		run(limiter.Limit(), 10*time.Millisecond)
+	}
+	AssertEqual(t, limiter.Limit() > lowLimit, true)
+}
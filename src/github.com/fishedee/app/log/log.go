@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/fishedee/app/log/logs"
@@ -22,6 +23,7 @@ type Log interface {
 	Notice(format string, v ...interface{})
 	Informational(format string, v ...interface{})
 	Debug(format string, v ...interface{})
+	WithContext(ctx context.Context) Log
 	Close()
 }
 
@@ -44,6 +46,20 @@ type logImplement struct {
 	prettyPrint bool
 }
 
+type logRequestIdKey struct{}
+
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, logRequestIdKey{}, requestId)
+}
+
+func GetRequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestId, _ := ctx.Value(logRequestIdKey{}).(string)
+	return requestId
+}
+
 func getLevel(in string) int {
 	levelString := map[string]int{
 		"emergency":     logs.LevelEmergency,
@@ -168,6 +184,19 @@ func (this *logImplement) Trace(format string, v ...interface{}) {
 	this.BeeLogger.Trace(this.getLogFormat(format, v))
 }
 
+func (this *logImplement) WithContext(ctx context.Context) Log {
+	//同一个请求的日志都带上请求ID
+	requestId := GetRequestId(ctx)
+	if requestId == "" {
+		return this
+	}
+	return &logImplement{
+		BeeLogger:   this.BeeLogger,
+		logPrefix:   "[" + requestId + "]",
+		prettyPrint: this.prettyPrint,
+	}
+}
+
 func (this *logImplement) Close() {
 	this.BeeLogger.Close()
 }
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"fmt"
+	. "github.com/fishedee/app/accesslog"
+	. "github.com/fishedee/app/log"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	. "github.com/fishedee/app/validator"
+	"net/http"
+	"time"
+)
+
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (this *accessLogWriter) WriteHeader(status int) {
+	if this.status == 0 {
+		this.status = status
+	}
+	this.ResponseWriter.WriteHeader(status)
+}
+
+func (this *accessLogWriter) Write(data []byte) (int, error) {
+	if this.status == 0 {
+		this.status = http.StatusOK
+	}
+	n, err := this.ResponseWriter.Write(data)
+	this.bytes += int64(n)
+	return n, err
+}
+
+func (this *accessLogWriter) Flush() {
+	if flusher, isOk := this.ResponseWriter.(http.Flusher); isOk {
+		flusher.Flush()
+	}
+}
+
+type accessLogSessionWriter struct {
+	header http.Header
+}
+
+func (this *accessLogSessionWriter) Header() http.Header {
+	return this.header
+}
+
+func (this *accessLogSessionWriter) WriteHeader(status int) {
+}
+
+func (this *accessLogSessionWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func getAccessLogUser(r *http.Request, header http.Header, sessionFactory SessionFactory, userKey string) string {
+	if sessionFactory == nil || userKey == "" {
+		return ""
+	}
+	//handler登录以后新的session id在响应的Set-Cookie里面，合并到请求的cookie再读取
+	cookies := map[string]string{}
+	for _, cookie := range r.Cookies() {
+		cookies[cookie.Name] = cookie.Value
+	}
+	for _, cookie := range (&http.Response{Header: header}).Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(cookies, cookie.Name)
+		} else {
+			cookies[cookie.Name] = cookie.Value
+		}
+	}
+	//没有cookie的请求不会有session，避免为每个匿名请求创建空的session
+	if len(cookies) == 0 {
+		return ""
+	}
+	sessionRequest := r.Clone(r.Context())
+	sessionRequest.Header.Del("Cookie")
+	for name, value := range cookies {
+		sessionRequest.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	//响应已经发送，session续期的cookie写到丢弃的头部里面
+	session := sessionFactory.Create(&accessLogSessionWriter{header: http.Header{}}, sessionRequest)
+	if session.Begin() != nil {
+		return ""
+	}
+	defer session.Commit()
+	value, err := session.Get(userKey)
+	if err != nil || value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func NewAccessLogMiddleware(accessLog AccessLog, validatorFactory ValidatorFactory, sessionFactory SessionFactory, userKey string) RouterMiddleware {
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+		route, _ := prev.Data["path"].(string)
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				beginTime := time.Now()
+				validator := validatorFactory.Create(r, nil)
+				writer := &accessLogWriter{ResponseWriter: w}
+				defer func() {
+					//handler崩溃时同样记录，状态码为500
+					p := recover()
+					//请求ID中间件在外层时从context拿到ID，在内层时只能从响应头拿到ID
+					requestId := GetRequestId(r.Context())
+					if requestId == "" {
+						requestId = w.Header().Get(requestIdHeader)
+					}
+					status := writer.status
+					if p != nil {
+						status = http.StatusInternalServerError
+					} else if status == 0 {
+						status = http.StatusOK
+					}
+					accessLog.Write(AccessLogEntry{
+						Time:      beginTime,
+						Method:    r.Method,
+						Route:     route,
+						Protocol:  r.Proto,
+						Host:      r.Host,
+						Status:    status,
+						Bytes:     writer.bytes,
+						Duration:  time.Since(beginTime),
+						RemoteIP:  validator.RemoteIP(),
+						UserAgent: r.UserAgent(),
+						Referer:   r.Referer(),
+						RequestId: requestId,
+						User:      getAccessLogUser(r, w.Header(), sessionFactory, userKey),
+					})
+					if p != nil {
+						panic(p)
+					}
+				}()
+				last(writer, r, param)
+			},
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"encoding/json"
+	. "github.com/fishedee/app/accesslog"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	. "github.com/fishedee/app/validator"
+	. "github.com/fishedee/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "accesslog")
+	defer os.RemoveAll(dir)
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+
+	for _, format := range []string{"combined", "json"} {
+		file := filepath.Join(dir, format+".log")
+		accessLog, err := NewAccessLog(nil, AccessLogConfig{Format: format, File: file})
+		AssertEqual(t, err, nil)
+
+		factory := NewRouterFactory()
+		factory.Use(NewRequestIdMiddleware())
+		factory.Use(NewAccessLogMiddleware(accessLog, validatorFactory, sessionFactory, "userId"))
+		factory.GET("/login", func(w http.ResponseWriter, r *http.Request) {
+			session := sessionFactory.Create(w, r)
+			session.MustBegin()
+			session.MustSet("userId", 10001)
+			session.MustCommit()
+		})
+		factory.GET("/user/:userId", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(201)
+			w.Write([]byte("hello"))
+		})
+		router := factory.Create()
+
+		r, _ := http.NewRequest("GET", "/login", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		cookie := w.Result().Cookies()[0]
+
+		r, _ = http.NewRequest("GET", "/user/123?token=abc", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.2")
+		r.Header.Set("User-Agent", "Mozilla \"5.0\"")
+		r.Header.Set("Referer", "http://a.com/")
+		r.Header.Set("X-Request-Id", "abc-123")
+		r.AddCookie(cookie)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		accessLog.Close()
+
+		data, _ := ioutil.ReadFile(file)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		AssertEqual(t, len(lines), 2, format)
+		if format == "combined" {
+			AssertEqual(t, regexp.MustCompile(`^127.0.0.1 - 10001 \[.+\] "GET /login HTTP/1.1" 200 - "-" "-" [0-9.]+ [0-9a-f]{32}$`).MatchString(lines[0]), true, lines[0])
+			AssertEqual(t, regexp.MustCompile(`^1.2.3.4 - 10001 \[.+\] "GET /user/:userId HTTP/1.1" 201 5 "http://a.com/" "Mozilla \\"5.0\\"" [0-9.]+ abc-123$`).MatchString(lines[1]), true, lines[1])
+		} else {
+			var entry map[string]interface{}
+			err := json.Unmarshal([]byte(lines[1]), &entry)
+			AssertEqual(t, err, nil)
+			delete(entry, "time")
+			delete(entry, "durationMs")
+			AssertEqual(t, entry, map[string]interface{}{
+				"method":    "GET",
+				"route":     "/user/:userId",
+				"protocol":  "HTTP/1.1",
+				"host":      "",
+				"status":    float64(201),
+				"bytes":     float64(5),
+				"remoteIp":  "1.2.3.4",
+				"userAgent": "Mozilla \"5.0\"",
+				"referer":   "http://a.com/",
+				"requestId": "abc-123",
+				"user":      "10001",
+			})
+		}
+	}
+
+	_, err := NewAccessLog(nil, AccessLogConfig{Format: "xml"})
+	AssertEqual(t, err != nil, true)
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/csrf"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	"net/http"
+)
+
+func NewCsrfMiddleware(csrf Csrf, sessionFactory SessionFactory) RouterMiddleware {
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+		path, _ := prev.Data["path"].(string)
+
+		//路由的Data["csrf"]为false，或者在配置的豁免路由里面时不做校验
+		if isCheck, isOk := prev.Data["csrf"].(bool); isOk && isCheck == false {
+			return prev
+		}
+		if csrf.IsExempt(path) {
+			return prev
+		}
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				session := sessionFactory.Create(w, r)
+				csrf.ServeHTTP(w, r, session, func(w http.ResponseWriter, r *http.Request) {
+					last(w, r, param)
+				})
+			},
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/csrf"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	. "github.com/fishedee/app/validator"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCsrf(t *testing.T) {
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	csrf, err := NewCsrf(CsrfConfig{
+		Exempt: []string{"/pay/*"},
+	})
+	AssertEqual(t, err, nil)
+
+	factory := NewRouterFactory()
+	factory.Use(NewCsrfMiddleware(csrf, sessionFactory))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetCsrfToken(r.Context())))
+	}
+	factory.GET("/form", handler)
+	factory.POST("/form", handler)
+	factory.POST("/pay/callback", handler)
+	factory.POST("/wechat", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"csrf": false,
+		},
+		Handler: handler,
+	})
+	router := factory.Create()
+
+	//第一次请求拿到session与token
+	r, _ := http.NewRequest("GET", "/form", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	token := w.Body.String()
+	AssertEqual(t, w.Code, 200)
+	AssertEqual(t, len(token), 64)
+	cookie := w.Result().Cookies()[0]
+
+	testCase := []struct {
+		method    string
+		url       string
+		header    string
+		form      string
+		hasCookie bool
+		status    int
+	}{
+		{"GET", "/form", "", "", true, 200},
+		{"POST", "/form", "", "", true, 403},
+		{"POST", "/form", "abc", "", true, 403},
+		{"POST", "/form", token, "", true, 200},
+		{"POST", "/form", "", token, true, 200},
+		{"POST", "/form", token, "", false, 403},
+		{"POST", "/pay/callback", "", "", false, 200},
+		{"POST", "/wechat", "", "", false, 200},
+	}
+	for index, singleTestCase := range testCase {
+		form := url.Values{}
+		if singleTestCase.form != "" {
+			form.Set("_csrf", singleTestCase.form)
+		}
+		r, _ := http.NewRequest(singleTestCase.method, singleTestCase.url, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if singleTestCase.header != "" {
+			r.Header.Set("X-CSRF-Token", singleTestCase.header)
+		}
+		if singleTestCase.hasCookie {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		if singleTestCase.status == 200 && singleTestCase.hasCookie {
+			AssertEqual(t, w.Body.String(), token, index)
+		}
+	}
+}
+
+func TestCsrfForm(t *testing.T) {
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	csrf, _ := NewCsrf(CsrfConfig{MaxFormSize: 1024})
+
+	factory := NewRouterFactory()
+	factory.Use(NewCsrfMiddleware(csrf, sessionFactory))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.Write([]byte(GetCsrfToken(r.Context())))
+			return
+		}
+		//校验token以后表单仍然可以读取
+		validator := validatorFactory.Create(r, nil)
+		w.Write([]byte(validator.MustForm("name")))
+	}
+	factory.GET("/form", handler)
+	factory.POST("/form", handler)
+	router := factory.Create()
+
+	r, _ := http.NewRequest("GET", "/form", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	token := w.Body.String()
+	cookie := w.Result().Cookies()[0]
+
+	multipartBody := "--fish\r\nContent-Disposition: form-data; name=\"name\"\r\n\r\nfish2\r\n" +
+		"--fish\r\nContent-Disposition: form-data; name=\"_csrf\"\r\n\r\n" + token + "\r\n--fish--\r\n"
+	testCase := []struct {
+		contentType string
+		body        string
+		status      int
+		output      string
+	}{
+		{"application/x-www-form-urlencoded", "name=fish&_csrf=" + token, 200, "fish"},
+		{"application/x-www-form-urlencoded", "name=fish&_csrf=abc", 403, ""},
+		{"multipart/form-data; boundary=fish", multipartBody, 200, "fish2"},
+		{"application/x-www-form-urlencoded", "name=" + strings.Repeat("a", 1024) + "&_csrf=" + token, 413, ""},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("POST", "/form", strings.NewReader(singleTestCase.body))
+		r.Header.Set("Content-Type", singleTestCase.contentType)
+		r.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		if singleTestCase.status == 200 {
+			AssertEqual(t, w.Body.String(), singleTestCase.output, index)
+		}
+	}
+}
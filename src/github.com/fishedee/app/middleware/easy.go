@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	. "github.com/fishedee/app/log"
 	. "github.com/fishedee/app/metric"
 	. "github.com/fishedee/app/quicktag"
@@ -10,10 +12,295 @@ import (
 	. "github.com/fishedee/app/validator"
 	. "github.com/fishedee/language"
 	"net/http"
+	"reflect"
+	"strconv"
 	"strings"
 )
 
+var (
+	easyContextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	easySessionType = reflect.TypeOf((*Session)(nil)).Elem()
+	easyErrorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func EasyHandlerType(handlerType reflect.Type) (reflect.Type, reflect.Type, bool) {
+	//支持func([ctx context.Context], [req T], [s Session]) ([R], error)，返回请求与响应的类型
+	if handlerType == nil || handlerType.Kind() != reflect.Func || handlerType.IsVariadic() {
+		return nil, nil, false
+	}
+	var requestType reflect.Type
+	var responseType reflect.Type
+	index := 0
+	if index < handlerType.NumIn() && handlerType.In(index) == easyContextType {
+		index++
+	}
+	if index < handlerType.NumIn() {
+		inType := handlerType.In(index)
+		if inType.Kind() == reflect.Struct ||
+			(inType.Kind() == reflect.Ptr && inType.Elem().Kind() == reflect.Struct) {
+			requestType = inType
+			index++
+		}
+	}
+	if index < handlerType.NumIn() && handlerType.In(index) == easySessionType {
+		index++
+	}
+	if index != handlerType.NumIn() {
+		return nil, nil, false
+	}
+	numOut := handlerType.NumOut()
+	if numOut == 0 || numOut > 2 || handlerType.Out(numOut-1) != easyErrorType {
+		return nil, nil, false
+	}
+	if numOut == 2 {
+		responseType = handlerType.Out(0)
+	}
+	return requestType, responseType, true
+}
+
+func getEasyTypedHandler(handler interface{}) (func(v Validator, s Session) interface{}, bool) {
+	//路由创建时分析一次函数签名，请求时只做绑定与调用
+	handlerValue := reflect.ValueOf(handler)
+	handlerType := handlerValue.Type()
+	requestType, responseType, isOk := EasyHandlerType(handlerType)
+	if isOk == false {
+		return nil, false
+	}
+	numIn := handlerType.NumIn()
+	return func(v Validator, s Session) interface{} {
+		args := make([]reflect.Value, 0, numIn)
+		for i := 0; i != numIn; i++ {
+			inType := handlerType.In(i)
+			if inType == easyContextType {
+				args = append(args, reflect.ValueOf(v.Request().Context()))
+			} else if inType == easySessionType {
+				args = append(args, reflect.ValueOf(&s).Elem())
+			} else if inType.Kind() == reflect.Ptr {
+				request := reflect.New(requestType.Elem())
+				v.MustBind(request.Interface())
+				args = append(args, request)
+			} else {
+				request := reflect.New(requestType)
+				v.MustBind(request.Interface())
+				args = append(args, request.Elem())
+			}
+		}
+		result := handlerValue.Call(args)
+		err := result[len(result)-1]
+		if err.IsNil() == false {
+			return err.Interface()
+		}
+		if responseType == nil {
+			return nil
+		}
+		return result[0].Interface()
+	}, true
+}
+
+type EasyConfig struct {
+	CodeField     string   `config:"codefield"`
+	MsgField      string   `config:"msgfield"`
+	DataField     string   `config:"datafield"`
+	Status        []string `config:"status"`
+	ErrorTemplate []string `config:"errortemplate"`
+	ErrorRedirect []string `config:"errorredirect"`
+	RunMode       string   `config:"runmode"`
+	ShowStack     bool     `config:"showstack"`
+}
+
+type easyStatus struct {
+	minCode int
+	maxCode int
+	status  int
+}
+
+type easyOption struct {
+	codeField     string
+	msgField      string
+	dataField     string
+	status        []easyStatus
+	errorTemplate map[string]string
+	errorRedirect map[string]string
+	showStack     bool
+}
+
+func parseEasyPair(config []string, defaultValue map[string]string) (map[string]string, error) {
+	//render:value的形式，value里面可能带有冒号，所以只切第一个
+	result := map[string]string{}
+	for key, value := range defaultValue {
+		result[key] = value
+	}
+	for _, single := range config {
+		index := strings.IndexByte(single, ':')
+		if index <= 0 {
+			return nil, errors.New("invalid easy config " + single)
+		}
+		result[strings.ToLower(strings.TrimSpace(single[0:index]))] = strings.TrimSpace(single[index+1:])
+	}
+	return result, nil
+}
+
+func parseEasyStatus(config []string) ([]easyStatus, error) {
+	//10001-10099:401或者10001:401的形式
+	result := []easyStatus{}
+	for _, single := range config {
+		index := strings.LastIndexByte(single, ':')
+		if index <= 0 {
+			return nil, errors.New("invalid easy status " + single)
+		}
+		status, err := strconv.Atoi(strings.TrimSpace(single[index+1:]))
+		if err != nil {
+			return nil, errors.New("invalid easy status " + single)
+		}
+		codeRange := Explode(single[0:index], "-")
+		if len(codeRange) == 0 || len(codeRange) > 2 {
+			return nil, errors.New("invalid easy status " + single)
+		}
+		minCode, err := strconv.Atoi(strings.TrimSpace(codeRange[0]))
+		if err != nil {
+			return nil, errors.New("invalid easy status " + single)
+		}
+		maxCode := minCode
+		if len(codeRange) == 2 {
+			maxCode, err = strconv.Atoi(strings.TrimSpace(codeRange[1]))
+			if err != nil {
+				return nil, errors.New("invalid easy status " + single)
+			}
+		}
+		result = append(result, easyStatus{
+			minCode: minCode,
+			maxCode: maxCode,
+			status:  status,
+		})
+	}
+	return result, nil
+}
+
+func newEasyOption(config EasyConfig) (*easyOption, error) {
+	option := &easyOption{
+		codeField: config.CodeField,
+		msgField:  config.MsgField,
+		dataField: config.DataField,
+		showStack: config.ShowStack && config.RunMode != "prod",
+	}
+	if option.codeField == "" {
+		option.codeField = "code"
+	}
+	if option.msgField == "" {
+		option.msgField = "msg"
+	}
+	if option.dataField == "" {
+		option.dataField = "data"
+	}
+	var err error
+	option.status, err = parseEasyStatus(config.Status)
+	if err != nil {
+		return nil, err
+	}
+	option.errorTemplate, err = parseEasyPair(config.ErrorTemplate, map[string]string{
+		"html": "error.html",
+	})
+	if err != nil {
+		return nil, err
+	}
+	option.errorRedirect, err = parseEasyPair(config.ErrorRedirect, map[string]string{
+		"redirect": "/",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+func (this *easyOption) getStatus(code int) int {
+	for _, single := range this.status {
+		if code >= single.minCode && code <= single.maxCode {
+			return single.status
+		}
+	}
+	return 0
+}
+
+func (this *easyOption) renderError(renderName string, err Exception) (string, interface{}) {
+	//出错时可以换成跳转或者错误模板，否则按原来的渲染方式输出错误信息
+	if redirect, isExist := this.errorRedirect[renderName]; isExist {
+		return "redirect", redirect
+	}
+	if template, isExist := this.errorTemplate[renderName]; isExist {
+		data := map[string]interface{}{
+			this.codeField: err.GetCode(),
+			this.msgField:  err.GetMessage(),
+		}
+		if this.showStack {
+			data["stack"] = err.GetStackTrace()
+		}
+		return "html", []interface{}{template, data}
+	}
+	if renderName == "raw" {
+		return renderName, []byte(err.GetMessage())
+	} else if renderName == "file" {
+		return renderName, "error.html"
+	} else if renderName == "json" || renderName == "xml" {
+		return renderName, map[string]interface{}{
+			this.codeField: err.GetCode(),
+			this.msgField:  err.GetMessage(),
+			this.dataField: nil,
+		}
+	} else if renderName == "text" {
+		return renderName, err.GetMessage()
+	} else if renderName == "csv" || renderName == "xlsx" || renderName == "sse" {
+		return "text", err.GetMessage()
+	} else {
+		return renderName, nil
+	}
+}
+
+type easyStatusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (this *easyStatusWriter) WriteHeader(status int) {
+	if this.wroteHeader {
+		return
+	}
+	this.wroteHeader = true
+	this.ResponseWriter.WriteHeader(status)
+}
+
+func (this *easyStatusWriter) Write(data []byte) (int, error) {
+	//formatter先设置Content-Type再写数据，状态码要等到第一次写的时候才能输出
+	if this.wroteHeader == false {
+		this.WriteHeader(this.status)
+	}
+	return this.ResponseWriter.Write(data)
+}
+
+func (this *easyStatusWriter) Flush() {
+	if this.wroteHeader == false {
+		this.WriteHeader(this.status)
+	}
+	if flusher, isOk := this.ResponseWriter.(http.Flusher); isOk {
+		flusher.Flush()
+	}
+}
+
 func NewEasyMiddleware(log Log, validatorFactory ValidatorFactory, sessionFactory SessionFactory, renderFactory RenderFactory, metric Metric) RouterMiddleware {
+	middleware, err := NewEasyMiddlewareWithConfig(log, validatorFactory, sessionFactory, renderFactory, metric, EasyConfig{
+		ShowStack: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return middleware
+}
+
+func NewEasyMiddlewareWithConfig(log Log, validatorFactory ValidatorFactory, sessionFactory SessionFactory, renderFactory RenderFactory, metric Metric, config EasyConfig) (RouterMiddleware, error) {
+	option, err := newEasyOption(config)
+	if err != nil {
+		return nil, err
+	}
 	var serverError MetricCounter
 	if metric != nil {
 		serverError = metric.GetCounter("server.error")
@@ -21,7 +308,12 @@ func NewEasyMiddleware(log Log, validatorFactory ValidatorFactory, sessionFactor
 	jsonQuickTag := NewQuickTag("json")
 
 	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		//模板中的url函数使用当前路由所在Router的反向生成，在渲染时放进请求的context
+		urlBuilder, _ := prev.Data["url"].(func(name string, params ...interface{}) (string, error))
 		lastHandler, isOk := prev.Handler.(func(v Validator, s Session) interface{})
+		if isOk == false {
+			lastHandler, isOk = getEasyTypedHandler(prev.Handler)
+		}
 		if isOk == false {
 			return prev
 		}
@@ -31,59 +323,28 @@ func NewEasyMiddleware(log Log, validatorFactory ValidatorFactory, sessionFactor
 			return prev
 		}
 		renderName := strings.ToLower(nameInfo[len(nameInfo)-1])
+		//外层的中间件通过Data["render"]拿到输出的格式，例如超时中间件不限制sse的长连接
+		prev.Data["render"] = renderName
 
-		renderChange := func(err Exception, result interface{}) interface{} {
-			if renderName == "raw" {
-				if err.GetCode() != 0 {
-					return []byte(err.GetMessage())
-				} else {
-					return result
-				}
-			} else if renderName == "file" {
-				if err.GetCode() != 0 {
-					return "error.html"
-				} else {
-					return result
-				}
-			} else if renderName == "text" {
-				if err.GetCode() != 0 {
-					return err.GetMessage()
-				} else {
-					return result
-				}
-			} else if renderName == "redirect" {
-				if err.GetCode() != 0 {
-					return []interface{}{302, "/"}
-				} else {
-					return result
-				}
-			} else if renderName == "json" {
-				if err.GetCode() != 0 {
-					return map[string]interface{}{
-						"code": err.GetCode(),
-						"msg":  err.GetMessage(),
-						"data": nil,
-					}
-				} else {
-					return map[string]interface{}{
-						"code": 0,
-						"msg":  "",
-						"data": jsonQuickTag.GetTagInstance(result),
-					}
-				}
-			} else if renderName == "html" {
-				if err.GetCode() != 0 {
-					return []interface{}{"error.html", map[string]interface{}{
-						"code":  err.GetCode(),
-						"msg":   err.GetMessage(),
-						"stack": err.GetStackTrace(),
-					}}
-				} else {
-					return result
-				}
-			} else {
-				return result
+		renderChange := func(renderName string, err Exception, result interface{}) (string, interface{}, int) {
+			if err.GetCode() != 0 {
+				errRenderName, data := option.renderError(renderName, err)
+				return errRenderName, data, option.getStatus(err.GetCode())
 			}
+			if renderName == "json" {
+				return renderName, map[string]interface{}{
+					option.codeField: 0,
+					option.msgField:  "",
+					option.dataField: jsonQuickTag.GetTagInstance(result),
+				}, 0
+			} else if renderName == "xml" {
+				return renderName, map[string]interface{}{
+					option.codeField: 0,
+					option.msgField:  "",
+					option.dataField: result,
+				}, 0
+			}
+			return renderName, result, 0
 		}
 		return RouterMiddlewareContext{
 			Data: prev.Data,
@@ -96,7 +357,6 @@ func NewEasyMiddleware(log Log, validatorFactory ValidatorFactory, sessionFactor
 				}
 				validator := validatorFactory.Create(r, param)
 				session := sessionFactory.Create(w, r)
-				render := renderFactory.Create(w, r)
 				var result interface{}
 				var exception Exception
 				func() {
@@ -105,7 +365,7 @@ func NewEasyMiddleware(log Log, validatorFactory ValidatorFactory, sessionFactor
 							serverError.Inc(1)
 						}
 						exception = e
-						log.Error("Buiness Error Code:[%d] Message:[%s]\nStackTrace:[%s]", e.GetCode(), e.GetMessage(), e.GetStackTrace())
+						log.WithContext(r.Context()).Error("Buiness Error Code:[%d] Message:[%s]\nStackTrace:[%s]", e.GetCode(), e.GetMessage(), e.GetStackTrace())
 					}
 					defer Catch(doException)
 					result = lastHandler(validator, session)
@@ -117,13 +377,28 @@ func NewEasyMiddleware(log Log, validatorFactory ValidatorFactory, sessionFactor
 						doException(*NewException(1, resultError.Error()))
 					}
 				}()
-				data := renderChange(exception, result)
-				err := render.Format(renderName, data)
+				//auto在每次请求时按Accept头决定输出的格式
+				currentRenderName := renderName
+				if currentRenderName == "auto" {
+					w.Header().Add("Vary", "Accept")
+					currentRenderName, result = GetAutoFormat(r, result)
+				}
+				resultRenderName, data, status := renderChange(currentRenderName, exception, result)
+				if resultRenderName == "html" && urlBuilder != nil {
+					r = r.WithContext(WithRenderUrlBuilder(r.Context(), urlBuilder))
+				}
+				var render Render
+				if status != 0 && resultRenderName != "redirect" {
+					render = renderFactory.Create(&easyStatusWriter{ResponseWriter: w, status: status}, r)
+				} else {
+					render = renderFactory.Create(w, r)
+				}
+				err := render.Format(resultRenderName, data)
 				if err != nil {
 					panic(err)
 				}
 			},
 		}
 
-	}
+	}, nil
 }
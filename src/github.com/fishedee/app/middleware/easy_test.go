@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"bufio"
+	gzip_ "compress/gzip"
 	"context"
 	"errors"
+	. "github.com/fishedee/app/gzip"
 	. "github.com/fishedee/app/log"
 	. "github.com/fishedee/app/render"
 	. "github.com/fishedee/app/router"
@@ -11,8 +14,14 @@ import (
 	. "github.com/fishedee/assert"
 	. "github.com/fishedee/encoding"
 	. "github.com/fishedee/language"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func a_json(v Validator, s Session) interface{} {
@@ -151,3 +160,369 @@ func TestEasy2(t *testing.T) {
 	router.ServeHTTP(w, r)
 	AssertEqual(t, jsonToArray(w.Read()), map[string]interface{}{"code": 0.0, "msg": "", "data": "contextDbValue"})
 }
+
+type easyUserReq struct {
+	UserId int
+	Name   string
+}
+
+type easyUserResp struct {
+	UserId int
+	Name   string
+	Db     string
+}
+
+func easyTypedAdd(ctx context.Context, req easyUserReq, s Session) (easyUserResp, error) {
+	if req.UserId == 0 {
+		return easyUserResp{}, NewException(10003, "userId is empty")
+	}
+	return easyUserResp{
+		UserId: req.UserId,
+		Name:   req.Name,
+		Db:     ctx.Value("db").(string),
+	}, nil
+}
+
+func easyTypedDel(req *easyUserReq) error {
+	if req.UserId == 0 {
+		return errors.New("userId is empty")
+	}
+	return nil
+}
+
+func TestEasyTyped(t *testing.T) {
+	log, _ := NewLog(LogConfig{Driver: "console"})
+	renderFactory, _ := NewRenderFactory(RenderConfig{})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	middleware := NewEasyMiddleware(log, validatorFactory, sessionFactory, renderFactory, nil)
+
+	factory := NewRouterFactory()
+	factory.Use(middleware)
+	factory.POST("/user/:userId", RouterMiddlewareContext{
+		Data:    map[string]interface{}{"name": "Add_Json"},
+		Handler: easyTypedAdd,
+	})
+	factory.DELETE("/user", RouterMiddlewareContext{
+		Data:    map[string]interface{}{"name": "Del_Json"},
+		Handler: easyTypedDel,
+	})
+	router := factory.Create()
+
+	testCase := []struct {
+		method      string
+		url         string
+		contentType string
+		body        string
+		data        interface{}
+	}{
+		{"POST", "http://example.com/user/10001", "application/json", `{"name":"fish"}`, map[string]interface{}{
+			"code": 0.0,
+			"msg":  "",
+			"data": map[string]interface{}{"userId": 10001.0, "name": "fish", "db": "contextDbValue"},
+		}},
+		{"POST", "http://example.com/user/0?name=fish", "", "", map[string]interface{}{
+			"code": 10003.0,
+			"msg":  "userId is empty",
+			"data": nil,
+		}},
+		{"DELETE", "http://example.com/user?userId=10001", "", "", map[string]interface{}{
+			"code": 0.0,
+			"msg":  "",
+			"data": nil,
+		}},
+		{"DELETE", "http://example.com/user", "", "", map[string]interface{}{
+			"code": 1.0,
+			"msg":  "userId is empty",
+			"data": nil,
+		}},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest(singleTestCase.method, singleTestCase.url, strings.NewReader(singleTestCase.body))
+		if singleTestCase.contentType != "" {
+			r.Header.Set("Content-Type", singleTestCase.contentType)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), "db", "contextDbValue"))
+		w := &fakeWriter{}
+		router.ServeHTTP(w, r)
+		AssertEqual(t, jsonToArray(w.Read()), singleTestCase.data, index)
+	}
+}
+
+func easyConfigUser_Json(v Validator, s Session) interface{} {
+	code, _ := strconv.Atoi(v.MustQuery("code"))
+	Throw(code, "error %v", code)
+	return nil
+}
+
+func easyConfigUser_Html(v Validator, s Session) interface{} {
+	Throw(10001, "need login")
+	return nil
+}
+
+func easyConfigUser_Redirect(v Validator, s Session) interface{} {
+	Throw(10001, "need login")
+	return nil
+}
+
+func TestEasyConfig(t *testing.T) {
+	log, _ := NewLog(LogConfig{Driver: "console"})
+	renderFactory, _ := NewRenderFactory(RenderConfig{TemplateDir: "testdata"})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+
+	testCase := []struct {
+		config EasyConfig
+		url    string
+		status int
+		header string
+		body   string
+	}{
+		{EasyConfig{}, "/json?code=10001", 200, "", `{"code":10001,"data":null,"msg":"error 10001"}`},
+		{EasyConfig{}, "/redirect", 302, "/", ""},
+		{EasyConfig{
+			CodeField: "errCode",
+			MsgField:  "errMsg",
+			DataField: "result",
+			Status:    []string{"10001-10099:401", "10100:403", "20000-29999:422"},
+		}, "/json?code=10050", 401, "", `{"errCode":10050,"errMsg":"error 10050","result":null}`},
+		{EasyConfig{
+			Status: []string{"10001-10099:401", "10100:403", "20000-29999:422"},
+		}, "/json?code=10100", 403, "", `{"code":10100,"data":null,"msg":"error 10100"}`},
+		{EasyConfig{
+			Status: []string{"10001-10099:401", "10100:403", "20000-29999:422"},
+		}, "/json?code=30000", 200, "", `{"code":30000,"data":null,"msg":"error 30000"}`},
+		{EasyConfig{
+			CodeField: "errCode",
+			MsgField:  "errMsg",
+			Status:    []string{"10001:401"},
+			ShowStack: true,
+		}, "/html", 401, "", "10001:need login:stack"},
+		{EasyConfig{
+			CodeField: "errCode",
+			MsgField:  "errMsg",
+			ShowStack: true,
+			RunMode:   "prod",
+		}, "/html", 200, "", "10001:need login:nostack"},
+		{EasyConfig{
+			ErrorRedirect: []string{"html:/login?from=html", "redirect:http://example.com/login"},
+		}, "/html", 302, "/login?from=html", ""},
+		{EasyConfig{
+			ErrorRedirect: []string{"html:/login?from=html", "redirect:http://example.com/login"},
+		}, "/redirect", 302, "http://example.com/login", ""},
+	}
+	for index, singleTestCase := range testCase {
+		middleware, err := NewEasyMiddlewareWithConfig(log, validatorFactory, sessionFactory, renderFactory, nil, singleTestCase.config)
+		AssertEqual(t, err, nil, index)
+		factory := NewRouterFactory()
+		factory.Use(middleware)
+		factory.GET("/json", easyConfigUser_Json)
+		factory.GET("/html", easyConfigUser_Html)
+		factory.GET("/redirect", easyConfigUser_Redirect)
+		router := factory.Create()
+
+		r, _ := http.NewRequest("GET", "http://example.com"+singleTestCase.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		AssertEqual(t, w.Header().Get("Location"), singleTestCase.header, index)
+		if singleTestCase.body != "" {
+			AssertEqual(t, strings.TrimSpace(w.Body.String()), singleTestCase.body, index)
+		}
+	}
+
+	_, err := NewEasyMiddlewareWithConfig(log, validatorFactory, sessionFactory, renderFactory, nil, EasyConfig{
+		Status: []string{"abc:401"},
+	})
+	AssertEqual(t, err != nil, true)
+}
+
+func easyUrlDetail_Html(v Validator, s Session) interface{} {
+	return []interface{}{"url.html", v.MustParam("id")}
+}
+
+func TestEasyUrl(t *testing.T) {
+	log, _ := NewLog(LogConfig{Driver: "console"})
+	renderFactory, _ := NewRenderFactory(RenderConfig{TemplateDir: "testdata"})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	middleware := NewEasyMiddleware(log, validatorFactory, sessionFactory, renderFactory, nil)
+
+	//两个Router共用同一个renderFactory，模板中的url按各自的路由生成
+	routers := []*Router{}
+	for _, prefix := range []string{"/a", "/b"} {
+		factory := NewRouterFactory()
+		factory.Use(middleware)
+		factory.GET(prefix+"/:id", easyUrlDetail_Html).Name("detail")
+		routers = append(routers, factory.Create())
+	}
+	for index, prefix := range []string{"/a", "/b", "/a"} {
+		r, _ := http.NewRequest("GET", "http://example.com"+prefix+"/1", nil)
+		w := httptest.NewRecorder()
+		routers[index%2].ServeHTTP(w, r)
+		AssertEqual(t, strings.TrimSpace(w.Body.String()), "<a href=\""+prefix+"/1\">detail</a>", index)
+	}
+}
+
+func TestEasyUrlHolder(t *testing.T) {
+	log, _ := NewLog(LogConfig{Driver: "console"})
+	renderFactory, _ := NewRenderFactory(RenderConfig{TemplateDir: "testdata"})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	middleware := NewEasyMiddleware(log, validatorFactory, sessionFactory, renderFactory, nil)
+
+	newFactory := func(prefix string, isConflict bool) *RouterFactory {
+		factory := NewRouterFactory()
+		factory.Use(middleware)
+		factory.GET("/:id", easyUrlDetail_Html)
+		factory.GET(prefix+"/:id", easyUrlDetail_Html).Name("detail")
+		if isConflict {
+			factory.GET("/:name", easyUrlDetail_Html)
+		}
+		return factory
+	}
+	serve := func(holder *RouterHolder) string {
+		r, _ := http.NewRequest("GET", "http://example.com/1", nil)
+		w := httptest.NewRecorder()
+		holder.ServeHTTP(w, r)
+		return strings.TrimSpace(w.Body.String())
+	}
+	holder := NewRouterHolder(newFactory("/a", false))
+	AssertEqual(t, serve(holder), "<a href=\"/a/1\">detail</a>")
+
+	//更新失败时旧的Router继续正常生成url
+	err := holder.Update(newFactory("/b", true))
+	AssertEqual(t, err != nil, true)
+	AssertEqual(t, serve(holder), "<a href=\"/a/1\">detail</a>")
+
+	//更新与请求并发时，每个请求都使用处理它的Router生成url
+	var wg sync.WaitGroup
+	for i := 0; i != 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			holder.MustUpdate(newFactory([]string{"/a", "/b"}[i%2], false))
+		}(i)
+		go func() {
+			defer wg.Done()
+			body := serve(holder)
+			AssertEqual(t, body == "<a href=\"/a/1\">detail</a>" || body == "<a href=\"/b/1\">detail</a>", true, body)
+		}()
+	}
+	wg.Wait()
+}
+
+func easyExportUser_Auto(v Validator, s Session) interface{} {
+	if v.MustQuery("code") != "" {
+		Throw(10001, "need login")
+	}
+	return RenderTable{
+		FileName: "user",
+		Column:   map[string]string{"name": "Name"},
+		Data:     []map[string]string{{"name": "fish"}},
+	}
+}
+
+func easyExportUser_Csv(v Validator, s Session) interface{} {
+	Throw(10001, "need login")
+	return nil
+}
+
+func easyExportUser_Xml(v Validator, s Session) interface{} {
+	return "fish"
+}
+
+func TestEasyAuto(t *testing.T) {
+	log, _ := NewLog(LogConfig{Driver: "console"})
+	renderFactory, _ := NewRenderFactory(RenderConfig{})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	middleware := NewEasyMiddleware(log, validatorFactory, sessionFactory, renderFactory, nil)
+
+	factory := NewRouterFactory()
+	factory.Use(middleware)
+	factory.GET("/auto", easyExportUser_Auto)
+	factory.GET("/csv", easyExportUser_Csv)
+	factory.GET("/xml", easyExportUser_Xml)
+	router := factory.Create()
+
+	testCase := []struct {
+		url         string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"/auto", "", "application/json; charset=utf-8", `{"code":0,"data":[{"name":"fish"}],"msg":""}`},
+		{"/auto", "text/csv", "text/csv; charset=utf-8", "\xEF\xBB\xBFName\nfish"},
+		{"/auto?code=1", "text/csv", "application/json; charset=utf-8", `{"code":10001,"data":null,"msg":"need login"}`},
+		{"/auto?code=1", "application/xml", "application/xml; charset=utf-8", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><code>10001</code><msg>need login</msg></response>"},
+		{"/csv", "", "text/plain; charset=utf-8", "need login"},
+		{"/xml", "", "application/xml; charset=utf-8", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><code>0</code><data>fish</data><msg></msg></response>"},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("GET", "http://example.com"+singleTestCase.url, nil)
+		if singleTestCase.accept != "" {
+			r.Header.Set("Accept", singleTestCase.accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Header().Get("Content-Type"), singleTestCase.contentType, index)
+		AssertEqual(t, strings.TrimSpace(w.Body.String()), singleTestCase.body, index)
+	}
+}
+
+func TestEasySse(t *testing.T) {
+	log, _ := NewLog(LogConfig{Driver: "console"})
+	renderFactory, _ := NewRenderFactory(RenderConfig{SseHeartbeat: time.Hour})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	gzip, _ := NewGzip(GzipConfig{})
+	timeout, _ := NewTimeoutMiddleware(TimeoutConfig{Timeout: 50 * time.Millisecond})
+	middleware := NewEasyMiddleware(log, validatorFactory, sessionFactory, renderFactory, nil)
+
+	channel := make(chan RenderEvent)
+	factory := NewRouterFactory()
+	factory.Use(NewGzipMiddleware(gzip))
+	factory.Use(timeout)
+	factory.Use(middleware)
+	factory.GET("/order/status", RouterMiddlewareContext{
+		Data: map[string]interface{}{"name": "OrderStatus_Sse"},
+		Handler: func(v Validator, s Session) interface{} {
+			if v.MustQuery("orderId") == "" {
+				Throw(10001, "invalid order")
+			}
+			return channel
+		},
+	})
+	server := httptest.NewServer(factory.Create())
+	defer server.Close()
+
+	//每个事件经过gzip以后立即推送，不受超时中间件的限制
+	r, _ := http.NewRequest("GET", server.URL+"/order/status?orderId=1", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(r)
+	AssertEqual(t, err, nil)
+	defer resp.Body.Close()
+	AssertEqual(t, resp.Header.Get("Content-Type"), "text/event-stream; charset=utf-8")
+	AssertEqual(t, resp.Header.Get("Content-Encoding"), "gzip")
+	reader, err := gzip_.NewReader(resp.Body)
+	AssertEqual(t, err, nil)
+	bufReader := bufio.NewReader(reader)
+	for i := 0; i != 2; i++ {
+		time.Sleep(60 * time.Millisecond)
+		channel <- RenderEvent{Event: "status", Data: i}
+		for _, line := range []string{"event:status\n", "data:" + strconv.Itoa(i) + "\n", "\n"} {
+			single, err := bufReader.ReadString('\n')
+			AssertEqual(t, err, nil, i)
+			AssertEqual(t, single, line, i)
+		}
+	}
+	close(channel)
+
+	r2, _ := http.NewRequest("GET", server.URL+"/order/status", nil)
+	resp2, err := http.DefaultClient.Do(r2)
+	AssertEqual(t, err, nil)
+	defer resp2.Body.Close()
+	body, _ := ioutil.ReadAll(resp2.Body)
+	AssertEqual(t, string(body), "invalid order")
+}
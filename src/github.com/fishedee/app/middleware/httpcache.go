@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/httpcache"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	"net/http"
+)
+
+func NewHttpCacheMiddleware(httpCache HttpCache, sessionFactory SessionFactory) RouterMiddleware {
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+
+		//只缓存Data["httpcache"]声明了规则的路由
+		rule, isOk := prev.Data["httpcache"].(HttpCacheRule)
+		if isOk == false || rule.Timeout <= 0 {
+			return prev
+		}
+		if len(rule.Session) != 0 && sessionFactory == nil {
+			panic("httpcache rule with session need a session factory")
+		}
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				var session Session
+				if len(rule.Session) != 0 {
+					session = sessionFactory.Create(w, r)
+				}
+				httpCache.ServeHTTP(w, r, session, rule, func(w http.ResponseWriter, r *http.Request) {
+					last(w, r, param)
+				})
+			},
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"context"
+	. "github.com/fishedee/app/cache"
+	. "github.com/fishedee/app/httpcache"
+	. "github.com/fishedee/app/render"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHttpCache(t *testing.T) {
+	cache, _ := NewCache(CacheConfig{Driver: "memory", SavePrefix: "cache:"})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	httpCache, err := NewHttpCache(cache, HttpCacheConfig{})
+	AssertEqual(t, err, nil)
+
+	counter := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		if r.URL.Query().Get("nostore") != "" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strconv.Itoa(counter)))
+	}
+	factory := NewRouterFactory()
+	factory.Use(NewHttpCacheMiddleware(httpCache, sessionFactory))
+	factory.GET("/list", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"httpcache": HttpCacheRule{
+				Timeout: time.Minute,
+				Query:   []string{"page"},
+				Vary:    []string{"Accept-Language"},
+				Tag:     []string{"list"},
+			},
+		},
+		Handler: handler,
+	})
+	factory.GET("/user", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"httpcache": HttpCacheRule{
+				Timeout: time.Minute,
+				Session: []string{"userId"},
+			},
+		},
+		Handler: handler,
+	})
+	factory.POST("/list", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"httpcache": HttpCacheRule{
+				Timeout: time.Minute,
+			},
+		},
+		Handler: handler,
+	})
+	factory.GET("/nocache", handler)
+	factory.GET("/login", func(w http.ResponseWriter, r *http.Request) {
+		session := sessionFactory.Create(w, r)
+		session.MustBegin()
+		session.MustSet("userId", r.URL.Query().Get("userId"))
+		session.MustCommit()
+	})
+	router := factory.Create()
+
+	testCase := []struct {
+		method   string
+		url      string
+		language string
+		purge    bool
+		body     string
+		xcache   string
+	}{
+		{"GET", "/list?page=1", "", false, "1", "MISS"},
+		{"GET", "/list?page=1", "", false, "1", "HIT"},
+		{"GET", "/list?page=1&t=123", "", false, "1", "HIT"},
+		{"GET", "/list?page=2", "", false, "2", "MISS"},
+		{"GET", "/list?page=1", "en", false, "3", "MISS"},
+		{"GET", "/list?page=1", "", true, "4", "MISS"},
+		{"GET", "/list?page=1", "", false, "4", "HIT"},
+		{"GET", "/list?page=3&nostore=1", "", false, "5", "MISS"},
+		{"GET", "/list?page=3&nostore=1", "", false, "6", "MISS"},
+		{"POST", "/list", "", false, "7", ""},
+		{"POST", "/list", "", false, "8", ""},
+		{"GET", "/nocache", "", false, "9", ""},
+		{"GET", "/user", "", false, "10", "MISS"},
+		{"GET", "/user", "", false, "10", "HIT"},
+	}
+	for index, singleTestCase := range testCase {
+		if singleTestCase.purge {
+			httpCache.MustPurge(context.Background(), "list")
+		}
+		r, _ := http.NewRequest(singleTestCase.method, singleTestCase.url, nil)
+		if singleTestCase.language != "" {
+			r.Header.Set("Accept-Language", singleTestCase.language)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, 200, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.body, index)
+		AssertEqual(t, w.Header().Get("X-Cache"), singleTestCase.xcache, index)
+		if singleTestCase.xcache == "HIT" {
+			AssertEqual(t, w.Header().Get("Content-Type"), "text/plain", index)
+		}
+	}
+
+	//不同用户的session使用不同的缓存
+	login := func(userId string) *http.Cookie {
+		r, _ := http.NewRequest("GET", "/login?userId="+userId, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Result().Cookies()[0]
+	}
+	userCase := []struct {
+		cookie *http.Cookie
+		body   string
+	}{
+		{login("1"), "11"},
+		{login("2"), "12"},
+	}
+	for round := 0; round != 2; round++ {
+		for index, singleTestCase := range userCase {
+			r, _ := http.NewRequest("GET", "/user", nil)
+			r.AddCookie(singleTestCase.cookie)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			AssertEqual(t, w.Body.String(), singleTestCase.body, index)
+		}
+	}
+}
+
+func TestHttpCacheRender(t *testing.T) {
+	cache, _ := NewCache(CacheConfig{Driver: "memory", SavePrefix: "cache:"})
+	httpCache, _ := NewHttpCache(cache, HttpCacheConfig{})
+	renderFactory, _ := NewRenderFactory(RenderConfig{})
+
+	counter := 0
+	jsonHandler := func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		renderFactory.Create(w, r).Format("json", counter)
+	}
+	varyHandler := func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		w.Header().Add("Vary", "Accept")
+		w.Write([]byte(r.Header.Get("Accept") + strconv.Itoa(counter)))
+	}
+	factory := NewRouterFactory()
+	factory.Use(NewHttpCacheMiddleware(httpCache, nil))
+	factory.GET("/json", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"httpcache": HttpCacheRule{Timeout: time.Minute},
+		},
+		Handler: jsonHandler,
+	})
+	factory.GET("/vary", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"httpcache": HttpCacheRule{Timeout: time.Minute},
+		},
+		Handler: varyHandler,
+	})
+	factory.GET("/vary_accept", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"httpcache": HttpCacheRule{Timeout: time.Minute, Vary: []string{"Accept"}},
+		},
+		Handler: varyHandler,
+	})
+	router := factory.Create()
+
+	testCase := []struct {
+		url    string
+		accept string
+		body   string
+		xcache string
+	}{
+		//json格式化器默认的no-store不影响路由的缓存规则
+		{"/json", "", "1\n", "MISS"},
+		{"/json", "", "1\n", "HIT"},
+		{"/json", "", "1\n", "HIT"},
+		//响应按缓存键以外的头部变化时不缓存
+		{"/vary", "xml", "xml2", "MISS"},
+		{"/vary", "json", "json3", "MISS"},
+		{"/vary_accept", "xml", "xml4", "MISS"},
+		{"/vary_accept", "json", "json5", "MISS"},
+		{"/vary_accept", "xml", "xml4", "HIT"},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("GET", singleTestCase.url, nil)
+		r.Header.Set("Accept", singleTestCase.accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Body.String(), singleTestCase.body, index)
+		AssertEqual(t, w.Header().Get("X-Cache"), singleTestCase.xcache, index)
+		AssertEqual(t, w.Header().Get("Cache-Control"), "private, no-cache", index)
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/idempotency"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	. "github.com/fishedee/app/validator"
+	"net/http"
+)
+
+type IdempotencyKey func(v Validator, s Session) string
+
+func IdempotencyKeyIP() IdempotencyKey {
+	return IdempotencyKey(RateLimitKeyIP())
+}
+
+func IdempotencyKeySession(name string) IdempotencyKey {
+	return IdempotencyKey(RateLimitKeySession(name))
+}
+
+func NewIdempotencyMiddleware(idempotency Idempotency, validatorFactory ValidatorFactory, sessionFactory SessionFactory, key IdempotencyKey) RouterMiddleware {
+	if key == nil {
+		key = IdempotencyKeyIP()
+	}
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+		path, _ := prev.Data["path"].(string)
+
+		//路由的Data["idempotency"]为false时不处理
+		if isCheck, isOk := prev.Data["idempotency"].(bool); isOk && isCheck == false {
+			return prev
+		}
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				//只有写请求才需要区分客户端，避免每个请求都读session
+				if r.Method != "POST" && r.Method != "PUT" && r.Method != "PATCH" {
+					last(w, r, param)
+					return
+				}
+				paramMap := map[string]string{}
+				for _, singleParam := range param {
+					paramMap[singleParam.Key] = singleParam.Value
+				}
+				validator := validatorFactory.Create(r, paramMap)
+				var session Session
+				if sessionFactory != nil {
+					session = sessionFactory.Create(w, r)
+				}
+				idempotency.ServeHTTP(w, r, path, key(validator, session), func(w http.ResponseWriter, r *http.Request) {
+					last(w, r, param)
+				})
+			},
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/cache"
+	. "github.com/fishedee/app/idempotency"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/validator"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIdempotency(t *testing.T) {
+	cache, _ := NewCache(CacheConfig{Driver: "memory", SavePrefix: "cache:"})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	idempotency, err := NewIdempotency(cache, IdempotencyConfig{MaxBodySize: 1024})
+	AssertEqual(t, err, nil)
+
+	counter := 0
+	wait := make(chan bool)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "" {
+			wait <- true
+			<-wait
+		}
+		counter++
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		if status != 0 {
+			w.WriteHeader(status)
+		}
+		w.Write([]byte(strconv.Itoa(counter)))
+	}
+	factory := NewRouterFactory()
+	factory.Use(NewIdempotencyMiddleware(idempotency, validatorFactory, nil, nil))
+	factory.GET("/order", handler)
+	factory.POST("/order", handler)
+	factory.POST("/pay", handler)
+	router := factory.Create()
+
+	requestClient := func(method string, url string, key string, addr string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, url, strings.NewReader(body))
+		r.RemoteAddr = addr
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	request := func(method string, url string, key string) *httptest.ResponseRecorder {
+		return requestClient(method, url, key, "10.0.0.1:1234", "")
+	}
+
+	testCase := []struct {
+		method   string
+		url      string
+		key      string
+		status   int
+		body     string
+		replayed string
+	}{
+		{"POST", "/order", "", 200, "1", ""},
+		{"POST", "/order", "", 200, "2", ""},
+		{"POST", "/order", "a", 200, "3", ""},
+		{"POST", "/order", "a", 200, "3", "true"},
+		{"POST", "/pay", "a", 200, "4", ""},
+		{"GET", "/order", "a", 200, "5", ""},
+		{"POST", "/order?status=400", "b", 400, "6", ""},
+		{"POST", "/order?status=400", "b", 400, "6", "true"},
+		{"POST", "/order?status=500", "c", 500, "7", ""},
+		{"POST", "/order?status=500", "c", 500, "8", ""},
+	}
+	for index, singleTestCase := range testCase {
+		w := request(singleTestCase.method, singleTestCase.url, singleTestCase.key)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.body, index)
+		AssertEqual(t, w.Header().Get("Idempotent-Replayed"), singleTestCase.replayed, index)
+	}
+
+	//第一个请求还在执行时返回409
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- request("POST", "/order?wait=1", "d")
+	}()
+	<-wait
+	w := request("POST", "/order?wait=1", "d")
+	AssertEqual(t, w.Code, 409)
+	wait <- true
+	w = <-done
+	AssertEqual(t, w.Code, 200)
+	AssertEqual(t, w.Body.String(), "9")
+	w = request("POST", "/order?wait=1", "d")
+	AssertEqual(t, w.Body.String(), "9")
+
+	//不同客户端的同一个key互不影响
+	w = requestClient("POST", "/order", "e", "10.0.0.1:1234", "")
+	AssertEqual(t, w.Body.String(), "10")
+	w = requestClient("POST", "/order", "e", "10.0.0.2:1234", "")
+	AssertEqual(t, w.Body.String(), "11")
+	AssertEqual(t, w.Header().Get("Idempotent-Replayed"), "")
+	w = requestClient("POST", "/order", "e", "10.0.0.1:1234", "")
+	AssertEqual(t, w.Body.String(), "10")
+	AssertEqual(t, w.Header().Get("Idempotent-Replayed"), "true")
+
+	//同一个key的请求内容不同时返回422，body仍然可以被handler读取
+	w = requestClient("POST", "/order?status=201", "f", "10.0.0.1:1234", "a=1")
+	AssertEqual(t, w.Code, 201)
+	AssertEqual(t, w.Body.String(), "12")
+	w = requestClient("POST", "/order?status=201", "f", "10.0.0.1:1234", "a=2")
+	AssertEqual(t, w.Code, 422)
+	w = requestClient("POST", "/order?status=202", "f", "10.0.0.1:1234", "a=1")
+	AssertEqual(t, w.Code, 422)
+	w = requestClient("POST", "/order?status=201", "f", "10.0.0.1:1234", "a=1")
+	AssertEqual(t, w.Code, 201)
+	AssertEqual(t, w.Body.String(), "12")
+	w = requestClient("POST", "/order", "g", "10.0.0.1:1234", strings.Repeat("a", 1025))
+	AssertEqual(t, w.Code, 413)
+}
+
+func TestIdempotencyBody(t *testing.T) {
+	cache, _ := NewCache(CacheConfig{Driver: "memory", SavePrefix: "cache:"})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	idempotency, _ := NewIdempotency(cache, IdempotencyConfig{})
+
+	factory := NewRouterFactory()
+	factory.Use(NewIdempotencyMiddleware(idempotency, validatorFactory, nil, nil))
+	factory.POST("/order", func(w http.ResponseWriter, r *http.Request) {
+		validator := validatorFactory.Create(r, nil)
+		w.Write([]byte(validator.MustForm("name")))
+	})
+	router := factory.Create()
+
+	for index, name := range []string{"fish", "fish"} {
+		r, _ := http.NewRequest("POST", "/order", strings.NewReader("name="+name))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Idempotency-Key", "a")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, 200, index)
+		AssertEqual(t, w.Body.String(), name, index)
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/loadshed"
+	. "github.com/fishedee/app/router"
+	"net/http"
+)
+
+func NewLoadShedMiddleware(loadShed LoadShed) RouterMiddleware {
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+		path, _ := prev.Data["path"].(string)
+		host, _ := prev.Data["host"].(string)
+
+		//路由的Data["concurrency"]优先于配置文件，0代表只受全局的限制
+		limit := loadShed.Limit(path)
+		if dataLimit, isOk := prev.Data["concurrency"].(int); isOk {
+			limit = dataLimit
+		}
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				loadShed.ServeHTTP(w, r, host, path, limit, func(w http.ResponseWriter, r *http.Request) {
+					last(w, r, param)
+				})
+			},
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/loadshed"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadShed(t *testing.T) {
+	loadShed, err := NewLoadShed(nil, LoadShedConfig{
+		MaxConcurrency: 3,
+		QueueTimeout:   50 * time.Millisecond,
+		Route: []string{
+			"/report 1",
+		},
+	})
+	AssertEqual(t, err, nil)
+
+	start := make(chan bool, 10)
+	wait := make(chan bool)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		start <- true
+		<-wait
+	}
+	factory := NewRouterFactory()
+	factory.Use(NewLoadShedMiddleware(loadShed))
+	factory.GET("/list", handler)
+	factory.GET("/report", handler)
+	factory.GET("/fast", func(w http.ResponseWriter, r *http.Request) {})
+	router := factory.Create()
+
+	request := func(url string) int {
+		r, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+	result := make(chan int, 10)
+	for _, url := range []string{"/report", "/list", "/list"} {
+		go func(url string) {
+			result <- request(url)
+		}(url)
+		<-start
+	}
+
+	//全局与路由的名额都满了，排队超时以后返回503
+	AssertEqual(t, request("/fast"), 503)
+	AssertEqual(t, request("/report"), 503)
+
+	//排队期间有名额释放的请求可以执行
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		wait <- true
+	}()
+	AssertEqual(t, request("/fast"), 200)
+	AssertEqual(t, <-result, 200)
+
+	close(wait)
+	AssertEqual(t, <-result, 200)
+	AssertEqual(t, <-result, 200)
+	AssertEqual(t, request("/report"), 200)
+}
+
+func TestLoadShedRoute(t *testing.T) {
+	loadShed, _ := NewLoadShed(nil, LoadShedConfig{QueueTimeout: 20 * time.Millisecond})
+
+	start := make(chan bool)
+	wait := make(chan bool)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "" {
+			start <- true
+			<-wait
+		}
+	}
+	newFactory := func(concurrency int) *RouterFactory {
+		route := RouterMiddlewareContext{
+			Data: map[string]interface{}{
+				"concurrency": concurrency,
+			},
+			Handler: handler,
+		}
+		factory := NewRouterFactory()
+		factory.Use(NewLoadShedMiddleware(loadShed))
+		factory.GET("/export", route)
+		factory.POST("/export", route)
+		factory.Host("admin.com", func(factory *RouterFactory) {
+			factory.GET("/export", route)
+		})
+		return factory
+	}
+	holder := NewRouterHolder(newFactory(1))
+
+	request := func(method string, url string) int {
+		r, _ := http.NewRequest(method, url, nil)
+		w := httptest.NewRecorder()
+		holder.ServeHTTP(w, r)
+		return w.Code
+	}
+	result := make(chan int)
+	go func() {
+		result <- request("GET", "/export?wait=1")
+	}()
+	<-start
+
+	//不同方法与不同域名的同一个路径各自限制
+	AssertEqual(t, request("GET", "/export"), 503)
+	AssertEqual(t, request("POST", "/export"), 200)
+	AssertEqual(t, request("GET", "http://admin.com/export"), 200)
+
+	//路由更新以后使用新的限制
+	holder.MustUpdate(newFactory(2))
+	AssertEqual(t, request("GET", "/export"), 200)
+
+	close(wait)
+	AssertEqual(t, <-result, 200)
+}
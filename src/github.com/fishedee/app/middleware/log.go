@@ -23,7 +23,7 @@ func NewLogMiddleware(log Log, metric Metric) RouterMiddleware {
 				if serverCrash != nil {
 					serverCrash.Inc(1)
 				}
-				log.Critical("Buiness Crash Code:[%d] Message:[%s]\nStackTrace:[%s]", exception.GetCode(), exception.GetMessage(), exception.GetStackTrace())
+				log.WithContext(r.Context()).Critical("Buiness Crash Code:[%d] Message:[%s]\nStackTrace:[%s]", exception.GetCode(), exception.GetMessage(), exception.GetStackTrace())
 				w.WriteHeader(500)
 				w.Write([]byte("server internal error"))
 			})
@@ -39,7 +39,7 @@ func NewLogMiddleware(log Log, metric Metric) RouterMiddleware {
 				if serverRequest != nil {
 					serverRequest.Update(duration)
 				}
-				log.Debug("%s %s : %s", r.Method, r.URL.String(), duration.String())
+				log.WithContext(r.Context()).Debug("%s %s : %s", r.Method, r.URL.String(), duration.String())
 			},
 		}
 
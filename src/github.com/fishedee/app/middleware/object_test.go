@@ -118,8 +118,12 @@ func TestRouterObject(t *testing.T) {
 			if singleTestCase.method == "ANY" ||
 				singleTestCase.method == entrys[i] {
 				AssertEqual(t, w.Read(), singleTestCase.data, index)
+			} else if singleTestCase.method == "GET" && entrys[i] == "HEAD" {
+				AssertEqual(t, w.Read(), "", index)
+			} else if entrys[i] == "OPTIONS" {
+				AssertEqual(t, w.Read(), "", index)
 			} else {
-				AssertEqual(t, w.Read(), "404", index)
+				AssertEqual(t, w.Read(), "405 method not allowed By Fish", index)
 			}
 		}
 	}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"fmt"
+	. "github.com/fishedee/app/log"
+	. "github.com/fishedee/app/ratelimit"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	. "github.com/fishedee/app/validator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RateLimitKey func(v Validator, s Session) string
+
+func RateLimitKeyIP() RateLimitKey {
+	return func(v Validator, s Session) string {
+		return "ip:" + v.RemoteIP()
+	}
+}
+
+func RateLimitKeySession(name string) RateLimitKey {
+	return func(v Validator, s Session) string {
+		//未登录的请求按ip限流
+		if s != nil && s.Begin() == nil {
+			value, err := s.Get(name)
+			s.Commit()
+			if err == nil && value != nil && fmt.Sprintf("%v", value) != "" {
+				return "session:" + fmt.Sprintf("%v", value)
+			}
+		}
+		return "ip:" + v.RemoteIP()
+	}
+}
+
+func getRateLimitSecond(duration time.Duration) string {
+	//头部以秒为单位，不足一秒的向上取整
+	return strconv.Itoa(int((duration + time.Second - 1) / time.Second))
+}
+
+func NewRateLimitMiddleware(log Log, rateLimit RateLimit, validatorFactory ValidatorFactory, sessionFactory SessionFactory, key RateLimitKey) RouterMiddleware {
+	if key == nil {
+		key = RateLimitKeyIP()
+	}
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+		path, _ := prev.Data["path"].(string)
+
+		//路由的Data["ratelimit"]优先于配置文件，单独配置的路由使用独立的令牌桶
+		rule, isRoute := rateLimit.Rule(path)
+		if dataRule, isOk := prev.Data["ratelimit"].(RateLimitRule); isOk {
+			//与配置文件的规则一样补全默认值，Burst为0时等于Rate
+			var err error
+			rule, err = NewRateLimitRule(dataRule.Rate, dataRule.Period, dataRule.Burst)
+			if err != nil {
+				panic(err)
+			}
+			isRoute = true
+		}
+		keyPrefix := ""
+		if isRoute {
+			keyPrefix = path + ":"
+		}
+		if rule.Rate <= 0 {
+			return prev
+		}
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				paramMap := map[string]string{}
+				for _, singleParam := range param {
+					paramMap[singleParam.Key] = singleParam.Value
+				}
+				validator := validatorFactory.Create(r, paramMap)
+				var session Session
+				if sessionFactory != nil {
+					session = sessionFactory.Create(w, r)
+				}
+				result, err := rateLimit.Take(keyPrefix+key(validator, session), rule)
+				if err != nil {
+					//限流的存储不可用时放行，不影响正常的业务，但要记录下来
+					log.WithContext(r.Context()).Error("RateLimit Take Error Path:[%s] Error:[%s]", path, err.Error())
+					last(w, r, param)
+					return
+				}
+				header := w.Header()
+				header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+				header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+				header.Set("X-RateLimit-Reset", getRateLimitSecond(result.Reset))
+				if result.Allow == false {
+					header.Set("Retry-After", getRateLimitSecond(result.RetryAfter))
+					http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+					return
+				}
+				last(w, r, param)
+			},
+		}
+	}
+}
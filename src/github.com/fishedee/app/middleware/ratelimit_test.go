@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/fishedee/app/log"
+	. "github.com/fishedee/app/ratelimit"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/validator"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type rateLimitTestLog struct {
+	Log
+	message []string
+}
+
+func (this *rateLimitTestLog) Error(format string, v ...interface{}) {
+	this.message = append(this.message, fmt.Sprintf(format, v...))
+}
+
+func (this *rateLimitTestLog) WithContext(ctx context.Context) Log {
+	return this
+}
+
+type rateLimitErrorStore struct {
+	RateLimit
+}
+
+func (this *rateLimitErrorStore) Rule(path string) (RateLimitRule, bool) {
+	return RateLimitRule{Rate: 1, Period: time.Second, Burst: 1}, false
+}
+
+func (this *rateLimitErrorStore) Take(key string, rule RateLimitRule) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("connection refused")
+}
+
+func TestRateLimit(t *testing.T) {
+	rateLimit, _ := NewRateLimit(RateLimitConfig{
+		Driver: "memory",
+		Rate:   2,
+		Period: time.Hour,
+		Route: []string{
+			"/b 1/1h",
+		},
+	})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	log := &rateLimitTestLog{}
+
+	factory := NewRouterFactory()
+	factory.Use(NewRateLimitMiddleware(log, rateLimit, validatorFactory, nil, nil))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}
+	factory.GET("/a", handler)
+	factory.GET("/b", handler)
+	factory.GET("/c", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"ratelimit": RateLimitRule{},
+		},
+		Handler: handler,
+	})
+	factory.GET("/d", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"ratelimit": RateLimitRule{Rate: 1, Period: time.Hour},
+		},
+		Handler: handler,
+	})
+	router := factory.Create()
+
+	testCase := []struct {
+		url        string
+		remoteAddr string
+		status     int
+		remaining  string
+		retryAfter string
+	}{
+		{"/a", "1.1.1.1:80", 200, "1", ""},
+		{"/a", "1.1.1.1:81", 200, "0", ""},
+		{"/a", "1.1.1.1:82", 429, "0", "1800"},
+		{"/a", "2.2.2.2:80", 200, "1", ""},
+		{"/b", "1.1.1.1:80", 200, "0", ""},
+		{"/b", "1.1.1.1:80", 429, "0", "3600"},
+		{"/c", "1.1.1.1:80", 200, "", ""},
+		{"/c", "1.1.1.1:80", 200, "", ""},
+		{"/d", "1.1.1.1:80", 200, "0", ""},
+		{"/d", "1.1.1.1:80", 429, "0", "3600"},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("GET", singleTestCase.url, nil)
+		r.RemoteAddr = singleTestCase.remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		AssertEqual(t, w.Header().Get("X-RateLimit-Remaining"), singleTestCase.remaining, index)
+		AssertEqual(t, w.Header().Get("Retry-After"), singleTestCase.retryAfter, index)
+	}
+}
+
+func TestRateLimitStoreError(t *testing.T) {
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	log := &rateLimitTestLog{}
+
+	//存储不可用时放行并记录错误
+	factory := NewRouterFactory()
+	factory.Use(NewRateLimitMiddleware(log, &rateLimitErrorStore{}, validatorFactory, nil, nil))
+	factory.GET("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	router := factory.Create()
+
+	r, _ := http.NewRequest("GET", "/a", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	AssertEqual(t, w.Code, 200)
+	AssertEqual(t, w.Body.String(), "ok")
+	AssertEqual(t, log.message, []string{"RateLimit Take Error Path:[/a] Error:[connection refused]"})
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/requestbody"
+	. "github.com/fishedee/app/router"
+	"net/http"
+)
+
+func NewRequestBodyMiddleware(requestBody RequestBody) RouterMiddleware {
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+		path, _ := prev.Data["path"].(string)
+
+		//路由的Data["bodylimit"]优先于配置文件，0代表不限制大小
+		limit := requestBody.Limit(path)
+		if dataLimit, isOk := prev.Data["bodylimit"].(int64); isOk {
+			limit = dataLimit
+		}
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				requestBody.ServeHTTP(w, r, limit, func(w http.ResponseWriter, r *http.Request) {
+					last(w, r, param)
+				})
+			},
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	. "github.com/fishedee/app/requestbody"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRequestBody(t *testing.T) {
+	requestBody, err := NewRequestBody(RequestBodyConfig{
+		MaxSize:  "1k",
+		MaxRatio: 10,
+		Route: []string{
+			"/upload 4m",
+		},
+	})
+	AssertEqual(t, err, nil)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte(strconv.Itoa(len(data))))
+	}
+	factory := NewRouterFactory()
+	factory.Use(NewRequestBodyMiddleware(requestBody))
+	factory.POST("/form", handler)
+	factory.POST("/upload", handler)
+	factory.POST("/unlimit", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"bodylimit": int64(0),
+		},
+		Handler: handler,
+	})
+	router := factory.Create()
+
+	compress := func(encoding string, data string) []byte {
+		var buffer bytes.Buffer
+		var writer io.WriteCloser
+		if encoding == "gzip" {
+			writer = gzip.NewWriter(&buffer)
+		} else if encoding == "deflate" {
+			writer = zlib.NewWriter(&buffer)
+		} else {
+			writer, _ = flate.NewWriter(&buffer, flate.DefaultCompression)
+		}
+		writer.Write([]byte(data))
+		writer.Close()
+		return buffer.Bytes()
+	}
+
+	testCase := []struct {
+		url           string
+		encoding      string
+		body          []byte
+		contentLength bool
+		status        int
+		output        string
+	}{
+		{"/form", "", []byte("abc"), true, 200, "3"},
+		{"/form", "", []byte(strings.Repeat("a", 1024)), true, 200, "1024"},
+		{"/form", "", []byte(strings.Repeat("a", 1025)), true, 413, "Request Entity Too Large\n"},
+		{"/form", "", []byte(strings.Repeat("a", 1025)), false, 413, "Request Entity Too Large\n"},
+		{"/upload", "", []byte(strings.Repeat("a", 2048)), true, 200, "2048"},
+		{"/unlimit", "", []byte(strings.Repeat("a", 2048)), true, 200, "2048"},
+		{"/form", "gzip", compress("gzip", strings.Repeat("a", 1000)), true, 200, "1000"},
+		{"/form", "deflate", compress("deflate", strings.Repeat("a", 1000)), true, 200, "1000"},
+		{"/form", "deflate", compress("flate", strings.Repeat("a", 1000)), true, 200, "1000"},
+		{"/form", "gzip", compress("gzip", strings.Repeat("a", 2000)), true, 413, "Request Entity Too Large\n"},
+		{"/upload", "gzip", compress("gzip", strings.Repeat("a", 3*1024*1024)), true, 413, "Request Entity Too Large\n"},
+		{"/unlimit", "gzip", compress("gzip", strings.Repeat("a", 2000)), true, 200, "2000"},
+		{"/unlimit", "gzip", compress("gzip", strings.Repeat("a", 3*1024*1024)), true, 413, "Request Entity Too Large\n"},
+		{"/form", "br", []byte("abc"), true, 415, "Unsupported Media Type\n"},
+		{"/form", "gzip", []byte("abc"), true, 400, "invalid request body encoding\n"},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("POST", singleTestCase.url, bytes.NewReader(singleTestCase.body))
+		if singleTestCase.contentLength == false {
+			r.ContentLength = -1
+		}
+		if singleTestCase.encoding != "" {
+			r.Header.Set("Content-Encoding", singleTestCase.encoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.output, index)
+	}
+
+	_, err = NewRequestBody(RequestBodyConfig{MaxSize: "1x"})
+	AssertEqual(t, err != nil, true)
+}
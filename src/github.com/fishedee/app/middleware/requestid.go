@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	. "github.com/fishedee/app/log"
+	. "github.com/fishedee/app/router"
+	"net/http"
+)
+
+const requestIdHeader = "X-Request-Id"
+
+func isValidRequestId(requestId string) bool {
+	//外部传入的ID会写进日志与下游请求头，只接受短的可见字符
+	if len(requestId) == 0 || len(requestId) > 128 {
+		return false
+	}
+	for i := 0; i != len(requestId); i++ {
+		if requestId[i] <= ' ' || requestId[i] >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	data := make([]byte, 16)
+	_, err := rand.Read(data)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(data)
+}
+
+func NewRequestIdMiddleware() RouterMiddleware {
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				requestId := r.Header.Get(requestIdHeader)
+				if isValidRequestId(requestId) == false {
+					requestId = newRequestId()
+				}
+				w.Header().Set(requestIdHeader, requestId)
+				r = r.WithContext(WithRequestId(r.Context(), requestId))
+				last(w, r, param)
+			},
+		}
+	}
+}
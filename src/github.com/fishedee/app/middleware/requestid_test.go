@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/log"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestId(t *testing.T) {
+	log, _ := NewLog(LogConfig{
+		Driver: "console",
+	})
+
+	factory := NewRouterFactory()
+	factory.Use(NewRequestIdMiddleware())
+	factory.Use(NewLogMiddleware(log, nil))
+	factory.GET("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetRequestId(r.Context())))
+	})
+	router := factory.Create()
+
+	testCase := []struct {
+		requestId  string
+		isGenerate bool
+	}{
+		{"", true},
+		{"abc-123", false},
+		{"abc 123", true},
+		{strings.Repeat("a", 129), true},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("GET", "/a", nil)
+		if singleTestCase.requestId != "" {
+			r.Header.Set("X-Request-Id", singleTestCase.requestId)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		requestId := w.Header().Get("X-Request-Id")
+		AssertEqual(t, w.Body.String(), requestId, index)
+		if singleTestCase.isGenerate {
+			AssertEqual(t, len(requestId), 32, index)
+		} else {
+			AssertEqual(t, requestId, singleTestCase.requestId, index)
+		}
+	}
+}
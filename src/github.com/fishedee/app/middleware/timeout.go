@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/fishedee/app/router"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TimeoutConfig struct {
+	Timeout   time.Duration `config:"timeout"`
+	Route     []string      `config:"route"`
+	Status    int           `config:"status"`
+	Code      int           `config:"code"`
+	Msg       string        `config:"msg"`
+	CodeField string        `config:"codefield"`
+	MsgField  string        `config:"msgfield"`
+	DataField string        `config:"datafield"`
+}
+
+type timeoutWriter struct {
+	mutex       sync.Mutex
+	w           http.ResponseWriter
+	header      http.Header
+	buffer      bytes.Buffer
+	status      int
+	wroteHeader bool
+	timedOut    bool
+	flushed     bool
+}
+
+func (this *timeoutWriter) Header() http.Header {
+	return this.header
+}
+
+func (this *timeoutWriter) WriteHeader(status int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.timedOut || this.wroteHeader {
+		return
+	}
+	this.wroteHeader = true
+	this.status = status
+}
+
+func (this *timeoutWriter) Write(data []byte) (int, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if this.wroteHeader == false {
+		this.wroteHeader = true
+		this.status = http.StatusOK
+	}
+	if this.flushed {
+		return this.w.Write(data)
+	}
+	return this.buffer.Write(data)
+}
+
+func (this *timeoutWriter) Flush() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	//流式输出时先把缓冲的内容交给客户端，之后的输出直接写出，超时以后只能中断输出
+	if this.timedOut {
+		return
+	}
+	if this.flushed == false {
+		this.flushed = true
+		this.writeHeader()
+	}
+	this.w.Write(this.buffer.Bytes())
+	this.buffer.Reset()
+	if flusher, isOk := this.w.(http.Flusher); isOk {
+		flusher.Flush()
+	}
+}
+
+func (this *timeoutWriter) writeHeader() {
+	dst := this.w.Header()
+	for key, value := range this.header {
+		dst[key] = value
+	}
+	if this.wroteHeader {
+		this.w.WriteHeader(this.status)
+	} else {
+		this.w.WriteHeader(http.StatusOK)
+	}
+}
+
+func parseTimeoutRoute(routes []string) (map[string]time.Duration, error) {
+	//路由超时的格式为：/report/export 30s，0s代表不限时
+	result := map[string]time.Duration{}
+	for _, route := range routes {
+		fields := strings.Fields(route)
+		if len(fields) != 2 {
+			return nil, errors.New("invalid timeout route " + route)
+		}
+		timeout, err := time.ParseDuration(fields[1])
+		if err != nil || timeout < 0 {
+			return nil, errors.New("invalid timeout route " + route)
+		}
+		result[fields[0]] = timeout
+	}
+	return result, nil
+}
+
+func getTimeoutBody(config TimeoutConfig) (string, []byte, error) {
+	//没有配置错误码时输出纯文本，否则与EasyMiddleware一样输出json的包装
+	if config.Code == 0 {
+		msg := config.Msg
+		if msg == "" {
+			msg = http.StatusText(config.Status)
+		}
+		return "text/plain; charset=utf-8", []byte(msg), nil
+	}
+	if config.CodeField == "" {
+		config.CodeField = "code"
+	}
+	if config.MsgField == "" {
+		config.MsgField = "msg"
+	}
+	if config.DataField == "" {
+		config.DataField = "data"
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		config.CodeField: config.Code,
+		config.MsgField:  config.Msg,
+		config.DataField: nil,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return "application/json; charset=utf-8", data, nil
+}
+
+func NewTimeoutMiddleware(config TimeoutConfig) (RouterMiddleware, error) {
+	if config.Timeout < 0 {
+		return nil, errors.New("invalid timeout " + config.Timeout.String())
+	}
+	if config.Status == 0 {
+		config.Status = http.StatusServiceUnavailable
+	}
+	routeTimeout, err := parseTimeoutRoute(config.Route)
+	if err != nil {
+		return nil, err
+	}
+	contentType, body, err := getTimeoutBody(config)
+	if err != nil {
+		return nil, err
+	}
+	return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		last := prev.Handler.(func(w http.ResponseWriter, r *http.Request, param RouterParam))
+		path, _ := prev.Data["path"].(string)
+
+		timeout := config.Timeout
+		if singleTimeout, isExist := routeTimeout[path]; isExist {
+			timeout = singleTimeout
+		}
+		//sse是长连接，输出不能缓冲，默认不限时
+		if renderName, isOk := prev.Data["render"].(string); isOk && renderName == "sse" {
+			timeout = 0
+		}
+		//路由的Data["timeout"]优先于配置文件
+		if dataTimeout, isOk := prev.Data["timeout"].(time.Duration); isOk {
+			timeout = dataTimeout
+		}
+		if timeout <= 0 {
+			return prev
+		}
+		return RouterMiddlewareContext{
+			Data: prev.Data,
+			Handler: func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				ctx, cancel := context.WithTimeout(r.Context(), timeout)
+				defer cancel()
+				r = r.WithContext(ctx)
+
+				//handler在另外的goroutine执行，输出先写到缓冲里，超时以后丢弃
+				tw := &timeoutWriter{
+					w:      w,
+					header: http.Header{},
+				}
+				//超时返回以后param会放回Router的池子给其他请求复用，handler需要使用自己的副本
+				paramCopy := make(RouterParam, len(param))
+				copy(paramCopy, param)
+				done := make(chan struct{})
+				panicChan := make(chan interface{}, 1)
+				go func() {
+					defer func() {
+						if p := recover(); p != nil {
+							panicChan <- p
+						}
+					}()
+					last(tw, r, paramCopy)
+					close(done)
+				}()
+				select {
+				case p := <-panicChan:
+					panic(p)
+				case <-done:
+					tw.mutex.Lock()
+					defer tw.mutex.Unlock()
+					if tw.flushed == false {
+						dst := w.Header()
+						for key, value := range tw.header {
+							dst[key] = value
+						}
+						if tw.wroteHeader {
+							w.WriteHeader(tw.status)
+						}
+					}
+					w.Write(tw.buffer.Bytes())
+				case <-ctx.Done():
+					tw.mutex.Lock()
+					defer tw.mutex.Unlock()
+					tw.timedOut = true
+					if tw.flushed {
+						//已经开始输出的响应不能再改成超时的响应
+						return
+					}
+					w.Header().Set("Content-Type", contentType)
+					w.WriteHeader(config.Status)
+					w.Write(body)
+				}
+			},
+		}
+	}, nil
+}
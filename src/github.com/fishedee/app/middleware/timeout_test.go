@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	testCase := []struct {
+		config TimeoutConfig
+		url    string
+		status int
+		body   string
+	}{
+		{TimeoutConfig{Timeout: 50 * time.Millisecond}, "/fast", 201, "fast"},
+		{TimeoutConfig{Timeout: 50 * time.Millisecond}, "/wait", 503, "Service Unavailable"},
+		{TimeoutConfig{Timeout: 50 * time.Millisecond}, "/sleep", 503, "Service Unavailable"},
+		{TimeoutConfig{Timeout: 50 * time.Millisecond}, "/long", 200, "long"},
+		{TimeoutConfig{Timeout: 50 * time.Millisecond, Route: []string{"/sleep 1s"}}, "/sleep", 200, "sleep"},
+		{TimeoutConfig{Route: []string{"/wait 50ms"}}, "/wait", 503, "Service Unavailable"},
+		{TimeoutConfig{Timeout: 50 * time.Millisecond, Status: 504, Code: 10001, Msg: "timeout"}, "/wait", 504, `{"code":10001,"data":null,"msg":"timeout"}`},
+		{TimeoutConfig{Timeout: 50 * time.Millisecond, Code: 10001, Msg: "timeout", CodeField: "errorCode"}, "/wait", 503, `{"data":null,"errorCode":10001,"msg":"timeout"}`},
+	}
+	for index, singleTestCase := range testCase {
+		middleware, err := NewTimeoutMiddleware(singleTestCase.config)
+		AssertEqual(t, err, nil, index)
+
+		factory := NewRouterFactory()
+		factory.Use(middleware)
+		factory.GET("/fast", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Fast", "1")
+			w.WriteHeader(201)
+			w.Write([]byte("fast"))
+		})
+		factory.GET("/wait", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			w.Write([]byte("wait"))
+		})
+		factory.GET("/sleep", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte("sleep"))
+		})
+		factory.GET("/long", RouterMiddlewareContext{
+			Data: map[string]interface{}{
+				"timeout": time.Duration(0),
+			},
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(100 * time.Millisecond)
+				w.Write([]byte("long"))
+			},
+		})
+		router := factory.Create()
+
+		r, _ := http.NewRequest("GET", singleTestCase.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.body, index)
+		if singleTestCase.url == "/fast" {
+			AssertEqual(t, w.Header().Get("X-Fast"), "1", index)
+		}
+	}
+
+	_, err := NewTimeoutMiddleware(TimeoutConfig{Route: []string{"/a"}})
+	AssertEqual(t, err != nil, true)
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	middleware, _ := NewTimeoutMiddleware(TimeoutConfig{Timeout: time.Second})
+	factory := NewRouterFactory()
+	factory.Use(middleware)
+	factory.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("timeout panic")
+	})
+	router := factory.Create()
+
+	var result interface{}
+	func() {
+		defer func() {
+			result = recover()
+		}()
+		r, _ := http.NewRequest("GET", "/panic", nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+	AssertEqual(t, result, "timeout panic")
+}
+
+func TestTimeoutParam(t *testing.T) {
+	middleware, _ := NewTimeoutMiddleware(TimeoutConfig{Timeout: 20 * time.Millisecond})
+	factory := NewRouterFactory()
+	factory.Use(middleware)
+	result := make(chan string, 1)
+	factory.GET("/slow/:id", func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+		time.Sleep(100 * time.Millisecond)
+		result <- param[0].Value
+	})
+	factory.GET("/fast/:name", func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+		w.Write([]byte(param[0].Value))
+	})
+	router := factory.Create()
+
+	//超时以后其他请求复用了Router的param，超时的handler仍然拿到自己的参数
+	r, _ := http.NewRequest("GET", "/slow/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	AssertEqual(t, w.Code, 503)
+	for i := 0; i != 10; i++ {
+		r2, _ := http.NewRequest("GET", "/fast/abc", nil)
+		w2 := httptest.NewRecorder()
+		router.ServeHTTP(w2, r2)
+		AssertEqual(t, w2.Body.String(), "abc")
+	}
+	AssertEqual(t, <-result, "1")
+}
+
+func TestTimeoutFlush(t *testing.T) {
+	middleware, _ := NewTimeoutMiddleware(TimeoutConfig{Timeout: 50 * time.Millisecond})
+	factory := NewRouterFactory()
+	factory.Use(middleware)
+	factory.GET("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Stream", "1")
+		w.Write([]byte("a"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("b"))
+	})
+	factory.GET("/stream_wait", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		w.Write([]byte("b"))
+	})
+	router := factory.Create()
+
+	r, _ := http.NewRequest("GET", "/stream", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	AssertEqual(t, w.Code, 200)
+	AssertEqual(t, w.Body.String(), "ab")
+	AssertEqual(t, w.Header().Get("X-Stream"), "1")
+	AssertEqual(t, w.Flushed, true)
+
+	//已经开始输出以后超时，只能中断输出
+	r2, _ := http.NewRequest("GET", "/stream_wait", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, r2)
+	AssertEqual(t, w2.Code, 200)
+	AssertEqual(t, w2.Body.String(), "a")
+}
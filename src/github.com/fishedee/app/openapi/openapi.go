@@ -0,0 +1,373 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	. "github.com/fishedee/app/middleware"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/language"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type OpenApiOperation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Request     interface{}
+	Response    interface{}
+}
+
+type OpenApi interface {
+	Operation(method string, path string, operation OpenApiOperation)
+	Json(routes []RouterRoute) ([]byte, error)
+	Yaml(routes []RouterRoute) ([]byte, error)
+	Register(factory *RouterFactory, path string)
+}
+
+type OpenApiConfig struct {
+	Title      string `config:"title"`
+	Version    string `config:"version"`
+	SwaggerDir string `config:"swaggerdir"`
+	CodeField  string `config:"codefield"`
+	MsgField   string `config:"msgfield"`
+	DataField  string `config:"datafield"`
+}
+
+type openApiImplement struct {
+	config    OpenApiConfig
+	operation map[string]OpenApiOperation
+	mutex     sync.RWMutex
+	routes    atomic.Value
+}
+
+var openApiParamType = map[string]openApiObject{
+	"int":   {{"type", "integer"}, {"format", "int64"}},
+	"uint":  {{"type", "integer"}, {"format", "int64"}, {"minimum", 0}},
+	"float": {{"type", "number"}, {"format", "double"}},
+	"uuid":  {{"type", "string"}, {"format", "uuid"}},
+}
+
+func NewOpenApi(config OpenApiConfig) (OpenApi, error) {
+	if config.Title == "" {
+		return nil, errors.New("openapi title is empty")
+	}
+	if config.Version == "" {
+		config.Version = "1.0.0"
+	}
+	//与EasyConfig的包装字段保持一致
+	if config.CodeField == "" {
+		config.CodeField = "code"
+	}
+	if config.MsgField == "" {
+		config.MsgField = "msg"
+	}
+	if config.DataField == "" {
+		config.DataField = "data"
+	}
+	return &openApiImplement{
+		config:    config,
+		operation: map[string]OpenApiOperation{},
+	}, nil
+}
+
+func (this *openApiImplement) Operation(method string, path string, operation OpenApiOperation) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.operation[strings.ToUpper(method)+" "+path] = operation
+}
+
+func (this *openApiImplement) getOperation(route RouterRoute) OpenApiOperation {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.operation[route.Method+" "+route.Path]
+}
+
+func getRenderName(route RouterRoute) string {
+	//与EasyMiddleware一致，取方法名最后一段作为渲染方式
+	name, isOk := route.Data["name"].(string)
+	if isOk == false {
+		return ""
+	}
+	nameInfo := Explode(name, "_")
+	if len(nameInfo) == 0 {
+		return ""
+	}
+	return strings.ToLower(nameInfo[len(nameInfo)-1])
+}
+
+func isDocumentRoute(route RouterRoute) bool {
+	if route.Method == "HEAD" || route.Method == "OPTIONS" {
+		return false
+	}
+	if strings.HasPrefix(route.Handler, "Static(") {
+		return false
+	}
+	if isDocument, isOk := route.Data["openapi"].(bool); isOk {
+		return isDocument
+	}
+	return true
+}
+
+func getPathParam(route RouterRoute) (string, []interface{}, map[string]bool) {
+	//:id(int)转换为{id}，约束转换为参数的schema
+	path := ""
+	param := []interface{}{}
+	paramName := map[string]bool{}
+	for _, singlePath := range Explode(route.Path, "/") {
+		if singlePath[0] != ':' && singlePath[0] != '*' {
+			path += "/" + singlePath
+			continue
+		}
+		name := singlePath[1:]
+		schema := openApiObject{{"type", "string"}}
+		leftIndex := strings.IndexByte(name, '(')
+		if leftIndex != -1 {
+			expr := name[leftIndex+1 : len(name)-1]
+			name = name[0:leftIndex]
+			if paramType, isExist := openApiParamType[expr]; isExist {
+				schema = paramType
+			} else {
+				schema = schema.Set("pattern", "^(?:"+expr+")$")
+			}
+		}
+		path += "/{" + name + "}"
+		paramName[name] = true
+		param = append(param, openApiObject{
+			{"name", name},
+			{"in", "path"},
+			{"required", true},
+			{"schema", schema},
+		})
+	}
+	if path == "" {
+		path = "/"
+	}
+	return path, param, paramName
+}
+
+func getQueryParam(schema *openApiSchema, requestType reflect.Type, paramName map[string]bool) []interface{} {
+	//查询参数与表单使用validator标签，与MapToArray一致
+	result := []interface{}{}
+	for _, field := range getStructField(requestType, "validator") {
+		name := getFieldName(field, "validator")
+		if paramName[name] {
+			continue
+		}
+		result = append(result, openApiObject{
+			{"name", name},
+			{"in", "query"},
+			{"schema", schema.Schema(field.Type)},
+		})
+	}
+	return result
+}
+
+func getRequestBody(schema *openApiSchema, requestType reflect.Type) openApiObject {
+	formSchema := schema.Object(requestType, "validator")
+	return openApiObject{
+		{"content", openApiObject{
+			{"application/json", openApiObject{
+				{"schema", schema.Schema(requestType)},
+			}},
+			{"application/x-www-form-urlencoded", openApiObject{
+				{"schema", formSchema},
+			}},
+			{"multipart/form-data", openApiObject{
+				{"schema", formSchema},
+			}},
+		}},
+	}
+}
+
+func (this *openApiImplement) getResponse(schema *openApiSchema, renderName string, responseType reflect.Type) openApiObject {
+	content := func(contentType string, contentSchema openApiObject) openApiObject {
+		return openApiObject{
+			{"200", openApiObject{
+				{"description", "OK"},
+				{"content", openApiObject{
+					{contentType, openApiObject{
+						{"schema", contentSchema},
+					}},
+				}},
+			}},
+		}
+	}
+	switch renderName {
+	case "json":
+		//EasyMiddleware的包装，字段名与EasyConfig一致
+		return content("application/json", openApiObject{
+			{"type", "object"},
+			{"properties", openApiObject{
+				{this.config.CodeField, openApiObject{{"type", "integer"}}},
+				{this.config.MsgField, openApiObject{{"type", "string"}}},
+				{this.config.DataField, schema.Schema(responseType)},
+			}},
+		})
+	case "text":
+		return content("text/plain", openApiObject{{"type", "string"}})
+	case "html":
+		return content("text/html", openApiObject{{"type", "string"}})
+	case "raw", "file":
+		return content("application/octet-stream", openApiObject{{"type", "string"}, {"format", "binary"}})
+	case "redirect":
+		return openApiObject{
+			{"302", openApiObject{{"description", "Found"}}},
+		}
+	default:
+		return openApiObject{
+			{"200", openApiObject{{"description", "OK"}}},
+		}
+	}
+}
+
+func (this *openApiImplement) getDocument(routes []RouterRoute) openApiObject {
+	schema := newOpenApiSchema()
+	paths := openApiObject{}
+	for _, route := range routes {
+		if isDocumentRoute(route) == false {
+			continue
+		}
+		operation := this.getOperation(route)
+		path, param, paramName := getPathParam(route)
+		renderName := getRenderName(route)
+
+		result := openApiObject{}
+		if operation.Summary != "" {
+			result = result.Set("summary", operation.Summary)
+		} else if name, isOk := route.Data["name"].(string); isOk {
+			result = result.Set("summary", name)
+		}
+		if operation.Description != "" {
+			result = result.Set("description", operation.Description)
+		}
+		if route.Name != "" {
+			result = result.Set("operationId", route.Name)
+		}
+		tags := []interface{}{}
+		for _, tag := range operation.Tags {
+			tags = append(tags, tag)
+		}
+		if len(tags) != 0 {
+			result = result.Set("tags", tags)
+		}
+		requestType := reflect.TypeOf(operation.Request)
+		responseType := reflect.TypeOf(operation.Response)
+		if handlerRequestType, handlerResponseType, isOk := EasyHandlerType(route.HandlerType); isOk {
+			//强类型的handler直接从函数签名拿到请求与响应
+			if requestType == nil {
+				requestType = handlerRequestType
+			}
+			if responseType == nil {
+				responseType = handlerResponseType
+			}
+		}
+		if requestType != nil {
+			for requestType.Kind() == reflect.Ptr {
+				requestType = requestType.Elem()
+			}
+			if route.Method == "GET" || route.Method == "DELETE" {
+				param = append(param, getQueryParam(schema, requestType, paramName)...)
+			} else {
+				result = result.Set("requestBody", getRequestBody(schema, requestType))
+			}
+		}
+		if len(param) != 0 {
+			result = result.Set("parameters", param)
+		}
+		result = result.Set("responses", this.getResponse(schema, renderName, responseType))
+
+		pathItem, isExist := paths.Get(path)
+		if isExist == false {
+			pathItem = openApiObject{}
+		}
+		method := strings.ToLower(route.Method)
+		if _, isExist := pathItem.(openApiObject).Get(method); isExist {
+			//不同域名下的同一路由只保留第一个
+			continue
+		}
+		paths = paths.Set(path, pathItem.(openApiObject).Set(method, result))
+	}
+
+	document := openApiObject{
+		{"openapi", "3.0.3"},
+		{"info", openApiObject{
+			{"title", this.config.Title},
+			{"version", this.config.Version},
+		}},
+		{"paths", paths},
+	}
+	if len(schema.components) != 0 {
+		document = document.Set("components", openApiObject{
+			{"schemas", schema.components},
+		})
+	}
+	return document
+}
+
+func (this *openApiImplement) Json(routes []RouterRoute) ([]byte, error) {
+	return json.Marshal(this.getDocument(routes))
+}
+
+func (this *openApiImplement) Yaml(routes []RouterRoute) ([]byte, error) {
+	return encodeYaml(this.getDocument(routes))
+}
+
+func (this *openApiImplement) getRoutes() []RouterRoute {
+	routes, isOk := this.routes.Load().([]RouterRoute)
+	if isOk == false {
+		return []RouterRoute{}
+	}
+	return routes
+}
+
+func (this *openApiImplement) serveDocument(w http.ResponseWriter, contentType string, encode func(routes []RouterRoute) ([]byte, error)) {
+	data, err := encode(this.getRoutes())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
+
+func (this *openApiImplement) Register(factory *RouterFactory, path string) {
+	//文档路由本身不出现在文档里面
+	path = strings.TrimRight(path, "/")
+	factory.OnCreate(func(router *Router) {
+		this.routes.Store(router.Routes())
+	})
+	factory.GET(path+"/openapi.json", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"name":    "OpenApiJson",
+			"openapi": false,
+		},
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			this.serveDocument(w, "application/json; charset=utf-8", this.Json)
+		},
+	})
+	factory.GET(path+"/openapi.yaml", RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"name":    "OpenApiYaml",
+			"openapi": false,
+		},
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			this.serveDocument(w, "application/yaml; charset=utf-8", this.Yaml)
+		},
+	})
+	factory.GET(path, RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"name":    "OpenApiPage",
+			"openapi": false,
+		},
+		Handler: this.servePage,
+	})
+	if this.config.SwaggerDir != "" {
+		factory.Static(path+"/swagger", this.config.SwaggerDir)
+	}
+}
@@ -0,0 +1,216 @@
+package openapi
+
+import (
+	"encoding/json"
+	. "github.com/fishedee/app/log"
+	. "github.com/fishedee/app/middleware"
+	. "github.com/fishedee/app/render"
+	. "github.com/fishedee/app/router"
+	. "github.com/fishedee/app/session"
+	. "github.com/fishedee/app/validator"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type userAddress struct {
+	City string
+}
+
+type userInfo struct {
+	UserId     int `json:"id"`
+	Name       string
+	Address    []userAddress
+	Parent     *userInfo
+	CreateTime time.Time
+}
+
+type userSearch struct {
+	UserId int `validator:"id"`
+	Name   string
+	Limit  int
+}
+
+type userController struct {
+}
+
+func (this *userController) GET_Get_Json(w http.ResponseWriter, r *http.Request) {
+}
+
+func (this *userController) POST_Add_Json(w http.ResponseWriter, r *http.Request) {
+}
+
+func (this *userController) GET_Logout_Redirect(w http.ResponseWriter, r *http.Request) {
+}
+
+func getOpenApiDocument(t *testing.T, router *Router, path string) map[string]interface{} {
+	r, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	AssertEqual(t, w.Code, 200)
+	result := map[string]interface{}{}
+	err := json.Unmarshal(w.Body.Bytes(), &result)
+	AssertEqual(t, err, nil)
+	return result
+}
+
+func TestOpenApi(t *testing.T) {
+	openApi, err := NewOpenApi(OpenApiConfig{
+		Title: "user",
+	})
+	AssertEqual(t, err, nil)
+	openApi.Operation("GET", "/user/get", OpenApiOperation{
+		Tags:     []string{"user"},
+		Request:  userSearch{},
+		Response: []userInfo{},
+	})
+	openApi.Operation("POST", "/user/add", OpenApiOperation{
+		Summary: "add user",
+		Request: &userInfo{},
+	})
+
+	routerFactory := NewRouterFactory()
+	ObjectRouter(routerFactory, "/user", &userController{})
+	routerFactory.DELETE("/user/:id(int)/:name", func(w http.ResponseWriter, r *http.Request) {}).Name("userDel")
+	openApi.Register(routerFactory, "/doc")
+	router := routerFactory.Create()
+
+	document := getOpenApiDocument(t, router, "/doc/openapi.json")
+	AssertEqual(t, document["openapi"], "3.0.3")
+	AssertEqual(t, document["info"], map[string]interface{}{"title": "user", "version": "1.0.0"})
+
+	paths := document["paths"].(map[string]interface{})
+	AssertEqual(t, len(paths), 4)
+
+	//查询参数
+	getOperation := paths["/user/get"].(map[string]interface{})["get"].(map[string]interface{})
+	AssertEqual(t, getOperation["summary"], "GET_Get_Json")
+	AssertEqual(t, getOperation["tags"], []interface{}{"user"})
+	AssertEqual(t, getOperation["parameters"], []interface{}{
+		map[string]interface{}{"name": "id", "in": "query", "schema": map[string]interface{}{"type": "integer", "format": "int64"}},
+		map[string]interface{}{"name": "name", "in": "query", "schema": map[string]interface{}{"type": "string"}},
+		map[string]interface{}{"name": "limit", "in": "query", "schema": map[string]interface{}{"type": "integer", "format": "int64"}},
+	})
+	getResponse := getOperation["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"]
+	AssertEqual(t, getResponse, map[string]interface{}{
+		"schema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"code": map[string]interface{}{"type": "integer"},
+				"msg":  map[string]interface{}{"type": "string"},
+				"data": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"$ref": "#/components/schemas/userInfo"},
+				},
+			},
+		},
+	})
+
+	//请求体
+	addOperation := paths["/user/add"].(map[string]interface{})["post"].(map[string]interface{})
+	AssertEqual(t, addOperation["summary"], "add user")
+	addBody := addOperation["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+	AssertEqual(t, addBody["application/json"], map[string]interface{}{
+		"schema": map[string]interface{}{"$ref": "#/components/schemas/userInfo"},
+	})
+
+	//重定向与路径参数
+	logoutOperation := paths["/user/logout"].(map[string]interface{})["get"].(map[string]interface{})
+	AssertEqual(t, logoutOperation["responses"], map[string]interface{}{
+		"302": map[string]interface{}{"description": "Found"},
+	})
+	delOperation := paths["/user/{id}/{name}"].(map[string]interface{})["delete"].(map[string]interface{})
+	AssertEqual(t, delOperation["operationId"], "userDel")
+	AssertEqual(t, delOperation["parameters"], []interface{}{
+		map[string]interface{}{"name": "id", "in": "path", "required": true, "schema": map[string]interface{}{"type": "integer", "format": "int64"}},
+		map[string]interface{}{"name": "name", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+	})
+
+	//递归类型放在components
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	AssertEqual(t, schemas["userInfo"], map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":   map[string]interface{}{"type": "integer", "format": "int64"},
+			"name": map[string]interface{}{"type": "string"},
+			"address": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"$ref": "#/components/schemas/userAddress"},
+			},
+			"parent":     map[string]interface{}{"$ref": "#/components/schemas/userInfo"},
+			"createTime": map[string]interface{}{"type": "string", "format": "date-time"},
+		},
+	})
+}
+
+func TestOpenApiYamlAndPage(t *testing.T) {
+	openApi, _ := NewOpenApi(OpenApiConfig{
+		Title:   "user",
+		Version: "2.0.0",
+	})
+	routerFactory := NewRouterFactory()
+	ObjectRouter(routerFactory, "/user", &userController{})
+	openApi.Register(routerFactory, "/doc")
+	router := routerFactory.Create()
+
+	r, _ := http.NewRequest("GET", "/doc/openapi.yaml", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	AssertEqual(t, w.Code, 200)
+	lines := strings.Split(w.Body.String(), "\n")
+	AssertEqual(t, lines[0:6], []string{
+		"\"openapi\": \"3.0.3\"",
+		"\"info\":",
+		"  \"title\": \"user\"",
+		"  \"version\": \"2.0.0\"",
+		"\"paths\":",
+		"  \"/user/get\":",
+	})
+
+	r, _ = http.NewRequest("GET", "/doc", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	AssertEqual(t, w.Code, 200)
+	AssertEqual(t, strings.Contains(w.Body.String(), "<td>POST</td><td>/user/add</td><td>POST_Add_Json</td>"), true)
+	AssertEqual(t, strings.Contains(w.Body.String(), "/doc/openapi.json"), true)
+	AssertEqual(t, strings.Contains(w.Body.String(), "openapi.yaml</a></p>\n<table>"), true)
+	AssertEqual(t, strings.Contains(w.Body.String(), "OpenApi"), false)
+}
+
+func userTypedAdd(req userInfo) (userInfo, error) {
+	return req, nil
+}
+
+func TestOpenApiTypedHandler(t *testing.T) {
+	openApi, _ := NewOpenApi(OpenApiConfig{
+		Title: "user",
+	})
+	log, _ := NewLog(LogConfig{Driver: "console"})
+	renderFactory, _ := NewRenderFactory(RenderConfig{})
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	sessionFactory, _ := NewSessionFactory(SessionConfig{Driver: "memory", CookieName: "fishmm"})
+	routerFactory := NewRouterFactory()
+	routerFactory.Use(NewEasyMiddleware(log, validatorFactory, sessionFactory, renderFactory, nil))
+	routerFactory.POST("/user/add", RouterMiddlewareContext{
+		Data:    map[string]interface{}{"name": "Add_Json"},
+		Handler: userTypedAdd,
+	})
+	router := routerFactory.Create()
+
+	data, err := openApi.Json(router.Routes())
+	AssertEqual(t, err, nil)
+	document := map[string]interface{}{}
+	json.Unmarshal(data, &document)
+	addOperation := document["paths"].(map[string]interface{})["/user/add"].(map[string]interface{})["post"].(map[string]interface{})
+	addBody := addOperation["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+	AssertEqual(t, addBody["application/json"], map[string]interface{}{
+		"schema": map[string]interface{}{"$ref": "#/components/schemas/userInfo"},
+	})
+	addResponse := addOperation["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})
+	AssertEqual(t, addResponse["schema"].(map[string]interface{})["properties"].(map[string]interface{})["data"], map[string]interface{}{
+		"$ref": "#/components/schemas/userInfo",
+	})
+}
@@ -0,0 +1,84 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+var openApiSwaggerPage = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Path}}/swagger/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Path}}/swagger/swagger-ui-bundle.js"></script>
+<script>
+window.onload = function() {
+	window.ui = SwaggerUIBundle({
+		url: "{{.Path}}/openapi.json",
+		dom_id: "#swagger-ui"
+	});
+};
+</script>
+</body>
+</html>
+`))
+
+var openApiSimplePage = template.Must(template.New("simple").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}} {{.Version}}</h1>
+<p><a href="{{.Path}}/openapi.json">openapi.json</a> <a href="{{.Path}}/openapi.yaml">openapi.yaml</a></p>
+<table>
+<tr><th>METHOD</th><th>PATH</th><th>SUMMARY</th></tr>
+{{range .Routes}}<tr><td>{{.Method}}</td><td>{{.Path}}</td><td>{{.Summary}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type openApiPageRoute struct {
+	Method  string
+	Path    string
+	Summary string
+}
+
+func (this *openApiImplement) servePage(w http.ResponseWriter, r *http.Request) {
+	//没有配置swagger-ui的静态目录时，输出不依赖外部资源的简单列表
+	data := map[string]interface{}{
+		"Title":   this.config.Title,
+		"Version": this.config.Version,
+		"Path":    strings.TrimRight(r.URL.Path, "/"),
+	}
+	page := openApiSwaggerPage
+	if this.config.SwaggerDir == "" {
+		page = openApiSimplePage
+		routes := []openApiPageRoute{}
+		paths, _ := this.getDocument(this.getRoutes()).Get("paths")
+		for _, pathItem := range paths.(openApiObject) {
+			for _, operation := range pathItem.value.(openApiObject) {
+				summary, _ := operation.value.(openApiObject).Get("summary")
+				summaryString, _ := summary.(string)
+				routes = append(routes, openApiPageRoute{
+					Method:  strings.ToUpper(operation.key),
+					Path:    pathItem.key,
+					Summary: summaryString,
+				})
+			}
+		}
+		data["Routes"] = routes
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := page.Execute(w, data)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"encoding/json"
+	. "github.com/fishedee/language"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type openApiField struct {
+	key   string
+	value interface{}
+}
+
+type openApiObject []openApiField
+
+type openApiSchema struct {
+	components openApiObject
+	typeName   map[reflect.Type]string
+	nameType   map[string]reflect.Type
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(Decimal(""))
+	rawType     = reflect.TypeOf(json.RawMessage{})
+)
+
+func (this openApiObject) Set(key string, value interface{}) openApiObject {
+	for i, field := range this {
+		if field.key == key {
+			this[i].value = value
+			return this
+		}
+	}
+	return append(this, openApiField{key: key, value: value})
+}
+
+func (this openApiObject) Get(key string) (interface{}, bool) {
+	for _, field := range this {
+		if field.key == key {
+			return field.value, true
+		}
+	}
+	return nil, false
+}
+
+func (this openApiObject) MarshalJSON() ([]byte, error) {
+	//文档需要保持字段顺序，不能直接用map
+	result := []byte{'{'}
+	for i, field := range this {
+		if i != 0 {
+			result = append(result, ',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, key...)
+		result = append(result, ':')
+		result = append(result, value...)
+	}
+	result = append(result, '}')
+	return result, nil
+}
+
+func newOpenApiSchema() *openApiSchema {
+	return &openApiSchema{
+		components: openApiObject{},
+		typeName:   map[reflect.Type]string{},
+		nameType:   map[string]reflect.Type{},
+	}
+}
+
+func getFieldName(field reflect.StructField, tag string) string {
+	//与quicktag和MapToArray的命名规则一致，默认首字母小写
+	name := strings.ToLower(field.Name[0:1]) + field.Name[1:]
+	tagInfo, isExist := field.Tag.Lookup(tag)
+	if isExist {
+		tagName := Explode(tagInfo, ",")
+		if len(tagName) != 0 && tagName[0] != "" {
+			name = tagName[0]
+		}
+	}
+	return name
+}
+
+func getStructField(t reflect.Type, tag string) []reflect.StructField {
+	result := []reflect.StructField{}
+	for i := 0; i != t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get(tag) == "-" {
+			continue
+		}
+		if field.Anonymous {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				result = append(result, getStructField(fieldType, tag)...)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		result = append(result, field)
+	}
+	return result
+}
+
+func (this *openApiSchema) getTypeName(t reflect.Type) string {
+	name, isExist := this.typeName[t]
+	if isExist {
+		return name
+	}
+	name = t.Name()
+	if _, isExist := this.nameType[name]; isExist {
+		//不同包的同名类型加上包名区分
+		pkgPath := Explode(t.PkgPath(), "/")
+		if len(pkgPath) != 0 {
+			name = pkgPath[len(pkgPath)-1] + "." + name
+		}
+	}
+	this.typeName[t] = name
+	this.nameType[name] = t
+	return name
+}
+
+func (this *openApiSchema) Object(t reflect.Type, tag string) openApiObject {
+	properties := openApiObject{}
+	for _, field := range getStructField(t, tag) {
+		properties = properties.Set(getFieldName(field, tag), this.Schema(field.Type))
+	}
+	return openApiObject{
+		{"type", "object"},
+		{"properties", properties},
+	}
+}
+
+func (this *openApiSchema) Schema(t reflect.Type) openApiObject {
+	if t == nil {
+		return openApiObject{}
+	}
+	if t == timeType {
+		return openApiObject{{"type", "string"}, {"format", "date-time"}}
+	}
+	if t == decimalType {
+		return openApiObject{{"type", "string"}, {"format", "decimal"}}
+	}
+	if t == rawType {
+		return openApiObject{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return openApiObject{{"type", "boolean"}}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return openApiObject{{"type", "integer"}, {"format", "int64"}}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return openApiObject{{"type", "integer"}, {"format", "int32"}}
+	case reflect.Float32:
+		return openApiObject{{"type", "number"}, {"format", "float"}}
+	case reflect.Float64:
+		return openApiObject{{"type", "number"}, {"format", "double"}}
+	case reflect.String:
+		return openApiObject{{"type", "string"}}
+	case reflect.Ptr:
+		return this.Schema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return openApiObject{{"type", "string"}, {"format", "byte"}}
+		}
+		return openApiObject{{"type", "array"}, {"items", this.Schema(t.Elem())}}
+	case reflect.Map:
+		return openApiObject{{"type", "object"}, {"additionalProperties", this.Schema(t.Elem())}}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return this.Object(t, "json")
+		}
+		//具名结构体放到components里面，同时解决递归类型
+		_, isExist := this.typeName[t]
+		name := this.getTypeName(t)
+		if isExist == false {
+			this.components = this.components.Set(name, openApiObject{})
+			object := this.Object(t, "json")
+			this.components = this.components.Set(name, object)
+		}
+		return openApiObject{{"$ref", "#/components/schemas/" + name}}
+	default:
+		return openApiObject{}
+	}
+}
@@ -0,0 +1,94 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func encodeYaml(data interface{}) ([]byte, error) {
+	//文档只有对象，数组与标量三种结构，字符串统一用双引号避免转义问题
+	buffer := &bytes.Buffer{}
+	err := encodeYamlInner(buffer, data, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func isYamlScalar(data interface{}) bool {
+	switch value := data.(type) {
+	case openApiObject:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	default:
+		return true
+	}
+}
+
+func encodeYamlScalar(data interface{}) (string, error) {
+	switch value := data.(type) {
+	case openApiObject:
+		return "{}", nil
+	case []interface{}:
+		return "[]", nil
+	case nil:
+		return "null", nil
+	default:
+		result, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(result), nil
+	}
+}
+
+func encodeYamlInner(buffer *bytes.Buffer, data interface{}, indent int) error {
+	prefix := strings.Repeat("  ", indent)
+	switch value := data.(type) {
+	case openApiObject:
+		for _, field := range value {
+			key, _ := json.Marshal(field.key)
+			if isYamlScalar(field.value) {
+				scalar, err := encodeYamlScalar(field.value)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(buffer, "%s%s: %s\n", prefix, key, scalar)
+				continue
+			}
+			fmt.Fprintf(buffer, "%s%s:\n", prefix, key)
+			err := encodeYamlInner(buffer, field.value, indent+1)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, single := range value {
+			if isYamlScalar(single) {
+				scalar, err := encodeYamlScalar(single)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(buffer, "%s- %s\n", prefix, scalar)
+				continue
+			}
+			//数组元素的第一行跟在横杠后面
+			inner := &bytes.Buffer{}
+			err := encodeYamlInner(inner, single, indent+1)
+			if err != nil {
+				return err
+			}
+			buffer.WriteString(prefix + "- " + strings.TrimPrefix(inner.String(), prefix+"  "))
+		}
+	default:
+		scalar, err := encodeYamlScalar(value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buffer, "%s%s\n", prefix, scalar)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,6 +17,8 @@ type Queue interface {
 	Consume(topicId string, queue string, poolSize int, listener interface{}) error
 	MustConsume(topicId string, queue string, poolSize int, listener interface{})
 
+	WithContext(ctx context.Context) Queue
+
 	Run() error
 	Close()
 }
@@ -26,14 +29,23 @@ type QueueConfig struct {
 	Driver        string `config:"driver"`
 	Debug         bool   `config:"debug"`
 	RetryInterval int    `config:"retryinterval"`
+	RequestId     bool   `config:"requestid"`
 }
 
 type queueImplement struct {
 	store  queueStoreInterface
 	log    Log
 	config QueueConfig
+	ctx    context.Context
+}
+
+type queueMessage struct {
+	RequestId string          `json:"requestId"`
+	Data      json.RawMessage `json:"data"`
 }
 
+var queueContextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 func NewQueue(log Log, config QueueConfig) (Queue, error) {
 	if config.Driver == "" {
 		return nil, errors.New("invalid queue driver empty!")
@@ -77,7 +89,31 @@ func (this *queueImplement) encodeData(data []interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return dataByte, nil
+	//带上请求ID的消息用对象包装，旧版本的消费者不能解析，所以需要配置打开
+	//滚动发布时先让所有消费者升级到能解析两种格式的版本，再打开生产者的配置
+	if this.config.RequestId == false {
+		return dataByte, nil
+	}
+	requestId := GetRequestId(this.ctx)
+	if requestId == "" {
+		return dataByte, nil
+	}
+	return json.Marshal(queueMessage{
+		RequestId: requestId,
+		Data:      dataByte,
+	})
+}
+
+func (this *queueImplement) decodeMessage(dataByte []byte) (string, []byte, error) {
+	if len(dataByte) == 0 || dataByte[0] != '{' {
+		return "", dataByte, nil
+	}
+	message := queueMessage{}
+	err := json.Unmarshal(dataByte, &message)
+	if err != nil {
+		return "", nil, errors.New(err.Error() + "," + string(dataByte))
+	}
+	return message.RequestId, message.Data, nil
 }
 
 func (this *queueImplement) decodeData(dataByte []byte, dataType []reflect.Type) ([]reflect.Value, error) {
@@ -109,23 +145,41 @@ func (this *queueImplement) WrapExceptionListener(listener interface{}, debugPre
 		return nil, errors.New("listener type is not a function")
 	}
 	listenerInType := []reflect.Type{}
-	for i := 0; i != listenerType.NumIn(); i++ {
+	hasContext := listenerType.NumIn() != 0 && listenerType.In(0) == queueContextType
+	beginIn := 0
+	if hasContext {
+		beginIn = 1
+	}
+	for i := beginIn; i != listenerType.NumIn(); i++ {
 		listenerInType = append(
 			listenerInType,
 			listenerType.In(i),
 		)
 	}
 	return func(data []byte) {
+		//消费者沿用生产者的请求ID
+		ctx := context.Background()
+		requestId, data, err := this.decodeMessage(data)
+		if requestId != "" {
+			ctx = WithRequestId(ctx, requestId)
+		}
+		log := this.log.WithContext(ctx)
 		if this.config.Debug {
-			this.log.Debug("[Queue Consume] %v:%v", debugPrefix, string(data))
+			log.Debug("[Queue Consume] %v:%v", debugPrefix, string(data))
 		}
 		defer CatchCrash(func(exception Exception) {
-			this.log.Critical("QueueTask Crash Code:[%d] Message:[%s]\nStackTrace:[%s]", exception.GetCode(), exception.GetMessage(), exception.GetStackTrace())
+			log.Critical("QueueTask Crash Code:[%d] Message:[%s]\nStackTrace:[%s]", exception.GetCode(), exception.GetMessage(), exception.GetStackTrace())
 		})
+		if err != nil {
+			panic(err)
+		}
 		dataResult, err := this.decodeData(data, listenerInType)
 		if err != nil {
 			panic(err)
 		}
+		if hasContext {
+			dataResult = append([]reflect.Value{reflect.ValueOf(&ctx).Elem()}, dataResult...)
+		}
 		listenerValue.Call(dataResult)
 	}, nil
 }
@@ -140,7 +194,7 @@ func (this *queueImplement) Produce(topicId string, data ...interface{}) error {
 		return err
 	}
 	if this.config.Debug {
-		this.log.Debug("[Queue Produce] %v:%v", topicId, string(dataResult))
+		this.log.WithContext(this.ctx).Debug("[Queue Produce] %v:%v", topicId, string(dataResult))
 	}
 	return nil
 }
@@ -170,6 +224,15 @@ func (this *queueImplement) MustConsume(topicId string, queue string, poolSize i
 	}
 }
 
+func (this *queueImplement) WithContext(ctx context.Context) Queue {
+	return &queueImplement{
+		store:  this.store,
+		log:    this.log,
+		config: this.config,
+		ctx:    ctx,
+	}
+}
+
 func (this *queueImplement) Run() error {
 	return this.store.Run()
 }
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	. "github.com/fishedee/app/log"
 	. "github.com/fishedee/assert"
 	"github.com/garyburd/redigo/redis"
@@ -189,6 +190,47 @@ func TestQueuePoolSize(t *testing.T) {
 	}
 }
 
+func TestQueueRequestId(t *testing.T) {
+	queue := newQueueForTest(t, QueueConfig{
+		SavePrefix: "queue:",
+		Driver:     "memory",
+		RequestId:  true,
+	})
+	result := make(chan []interface{}, 10)
+	queue.MustConsume("queue5", "queue", 1, func(ctx context.Context, data int) {
+		result <- []interface{}{GetRequestId(ctx), data}
+	})
+	queue.MustConsume("queue6", "queue", 1, func(data int) {
+		result <- []interface{}{data}
+	})
+	go queue.Run()
+
+	ctx := WithRequestId(context.Background(), "request1")
+	queue.WithContext(ctx).MustProduce("queue5", 1)
+	AssertEqual(t, <-result, []interface{}{"request1", 1})
+	queue.MustProduce("queue5", 2)
+	AssertEqual(t, <-result, []interface{}{"", 2})
+	queue.WithContext(ctx).MustProduce("queue6", 3)
+	AssertEqual(t, <-result, []interface{}{3})
+	queue.Close()
+
+	//没有打开配置时保持原来的数组格式，旧版本的消费者可以解析
+	queue2 := newQueueForTest(t, QueueConfig{
+		SavePrefix: "queue:",
+		Driver:     "memory",
+	})
+	dataByte, err := queue2.WithContext(ctx).(*queueImplement).encodeData([]interface{}{4})
+	AssertEqual(t, err, nil)
+	AssertEqual(t, string(dataByte), "[4]")
+	queue2.MustConsume("queue7", "queue", 1, func(ctx context.Context, data int) {
+		result <- []interface{}{GetRequestId(ctx), data}
+	})
+	go queue2.Run()
+	queue2.WithContext(ctx).MustProduce("queue7", 4)
+	AssertEqual(t, <-result, []interface{}{"", 4})
+	queue2.Close()
+}
+
 func TestQueueClose(t *testing.T) {
 	testCase := []struct {
 		Queue Queue
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type memoryRateLimitBucket struct {
+	tokens   float64
+	lastTime time.Time
+	fullTime time.Time
+}
+
+type memoryRateLimitStore struct {
+	mutex     sync.Mutex
+	bucket    map[string]*memoryRateLimitBucket
+	takeCount int
+}
+
+const memoryRateLimitGcCount = 1024
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{
+		bucket: map[string]*memoryRateLimitBucket{},
+	}
+}
+
+func (this *memoryRateLimitStore) gc(now time.Time) {
+	//令牌已经补满的桶与新建的桶没有区别，可以直接删掉
+	for key, bucket := range this.bucket {
+		if now.After(bucket.fullTime) {
+			delete(this.bucket, key)
+		}
+	}
+}
+
+func (this *memoryRateLimitStore) take(key string, rule RateLimitRule, now time.Time) (bool, float64, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.takeCount++
+	if this.takeCount%memoryRateLimitGcCount == 0 {
+		this.gc(now)
+	}
+
+	bucket, isExist := this.bucket[key]
+	if isExist == false {
+		bucket = &memoryRateLimitBucket{
+			tokens:   float64(rule.Burst),
+			lastTime: now,
+		}
+		this.bucket[key] = bucket
+	}
+	if now.After(bucket.lastTime) {
+		elapsed := float64(now.Sub(bucket.lastTime))
+		bucket.tokens = math.Min(float64(rule.Burst), bucket.tokens+elapsed*float64(rule.Rate)/float64(rule.Period))
+		bucket.lastTime = now
+	}
+	allow := false
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		allow = true
+	}
+	bucket.fullTime = now.Add(getRuleDuration(rule, float64(rule.Burst)-bucket.tokens))
+	return allow, bucket.tokens, nil
+}
@@ -0,0 +1,188 @@
+package ratelimit
+
+import (
+	"errors"
+	. "github.com/fishedee/language"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RateLimitRule struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+type RateLimitResult struct {
+	Allow      bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+type RateLimit interface {
+	Rule(path string) (RateLimitRule, bool)
+	Take(key string, rule RateLimitRule) (RateLimitResult, error)
+	MustTake(key string, rule RateLimitRule) RateLimitResult
+}
+
+type RateLimitConfig struct {
+	Driver     string        `config:"driver"`
+	SavePath   string        `config:"savepath"`
+	SavePrefix string        `config:"saveprefix"`
+	Rate       int           `config:"rate"`
+	Period     time.Duration `config:"period"`
+	Burst      int           `config:"burst"`
+	Route      []string      `config:"route"`
+}
+
+type rateLimitStore interface {
+	take(key string, rule RateLimitRule, now time.Time) (bool, float64, error)
+}
+
+type rateLimitImplement struct {
+	store       rateLimitStore
+	savePrefix  string
+	defaultRule RateLimitRule
+	routeRule   map[string]RateLimitRule
+	now         func() time.Time
+}
+
+func NewRateLimitRule(rate int, period time.Duration, burst int) (RateLimitRule, error) {
+	//Period为0代表一秒，Burst为0代表与Rate相同
+	if rate < 0 || burst < 0 {
+		return RateLimitRule{}, errors.New("invalid rate limit rate or burst")
+	}
+	if period <= 0 {
+		period = time.Second
+	}
+	if burst == 0 {
+		burst = rate
+	}
+	return RateLimitRule{
+		Rate:   rate,
+		Period: period,
+		Burst:  burst,
+	}, nil
+}
+
+func parseRouteRule(route string) (string, RateLimitRule, error) {
+	//路由规则的格式为：/sms/send 1/1m 3，突发数量可以省略
+	fields := strings.Fields(route)
+	if len(fields) != 2 && len(fields) != 3 {
+		return "", RateLimitRule{}, errors.New("invalid rate limit route " + route)
+	}
+	rateInfo := Explode(fields[1], "/")
+	if len(rateInfo) != 2 {
+		return "", RateLimitRule{}, errors.New("invalid rate limit route " + route)
+	}
+	rate, err := strconv.Atoi(rateInfo[0])
+	if err != nil {
+		return "", RateLimitRule{}, errors.New("invalid rate limit route " + route)
+	}
+	period, err := time.ParseDuration(rateInfo[1])
+	if err != nil {
+		return "", RateLimitRule{}, errors.New("invalid rate limit route " + route)
+	}
+	burst := 0
+	if len(fields) == 3 {
+		burst, err = strconv.Atoi(fields[2])
+		if err != nil {
+			return "", RateLimitRule{}, errors.New("invalid rate limit route " + route)
+		}
+	}
+	rule, err := NewRateLimitRule(rate, period, burst)
+	if err != nil {
+		return "", RateLimitRule{}, err
+	}
+	return fields[0], rule, nil
+}
+
+func NewRateLimit(config RateLimitConfig) (RateLimit, error) {
+	var store rateLimitStore
+	var err error
+	if config.Driver == "memory" {
+		store = newMemoryRateLimitStore()
+	} else if config.Driver == "redis" {
+		if config.SavePrefix == "" {
+			return nil, errors.New("invalid config.SavePrefix is empty")
+		}
+		store, err = newRedisRateLimitStore(config.SavePath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("invalid rate limit driver " + config.Driver)
+	}
+
+	defaultRule, err := NewRateLimitRule(config.Rate, config.Period, config.Burst)
+	if err != nil {
+		return nil, err
+	}
+	routeRule := map[string]RateLimitRule{}
+	for _, route := range config.Route {
+		path, rule, err := parseRouteRule(route)
+		if err != nil {
+			return nil, err
+		}
+		routeRule[path] = rule
+	}
+	return &rateLimitImplement{
+		store:       store,
+		savePrefix:  config.SavePrefix,
+		defaultRule: defaultRule,
+		routeRule:   routeRule,
+		now:         time.Now,
+	}, nil
+}
+
+func (this *rateLimitImplement) Rule(path string) (RateLimitRule, bool) {
+	//第二个返回值代表是否为路由单独配置的规则
+	rule, isExist := this.routeRule[path]
+	if isExist {
+		return rule, true
+	}
+	return this.defaultRule, false
+}
+
+func getRuleDuration(rule RateLimitRule, tokens float64) time.Duration {
+	//补充指定数量的令牌需要的时间
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens * float64(rule.Period) / float64(rule.Rate)))
+}
+
+func (this *rateLimitImplement) Take(key string, rule RateLimitRule) (RateLimitResult, error) {
+	//Rate为0代表不限流
+	if rule.Rate <= 0 || rule.Burst <= 0 {
+		return RateLimitResult{
+			Allow: true,
+		}, nil
+	}
+	allow, tokens, err := this.store.take(this.savePrefix+key, rule, this.now())
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	result := RateLimitResult{
+		Allow:     allow,
+		Limit:     rule.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     getRuleDuration(rule, float64(rule.Burst)-tokens),
+	}
+	if allow == false {
+		result.RetryAfter = getRuleDuration(rule, 1-tokens)
+	}
+	return result, nil
+}
+
+func (this *rateLimitImplement) MustTake(key string, rule RateLimitRule) RateLimitResult {
+	result, err := this.Take(key, rule)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
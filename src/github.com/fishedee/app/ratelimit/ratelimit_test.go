@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	. "github.com/fishedee/assert"
+	"testing"
+	"time"
+)
+
+func TestRateLimitMemory(t *testing.T) {
+	rateLimit, err := NewRateLimit(RateLimitConfig{
+		Driver: "memory",
+		Rate:   2,
+		Period: time.Second,
+		Burst:  3,
+		Route: []string{
+			"/sms/send 1/1m",
+		},
+	})
+	AssertEqual(t, err, nil)
+	now := time.Unix(1000, 0)
+	rateLimit.(*rateLimitImplement).now = func() time.Time {
+		return now
+	}
+
+	rule, isRoute := rateLimit.Rule("/sms/send")
+	AssertEqual(t, rule, RateLimitRule{Rate: 1, Period: time.Minute, Burst: 1})
+	AssertEqual(t, isRoute, true)
+	rule, isRoute = rateLimit.Rule("/a")
+	AssertEqual(t, rule, RateLimitRule{Rate: 2, Period: time.Second, Burst: 3})
+	AssertEqual(t, isRoute, false)
+
+	testCase := []struct {
+		key    string
+		after  time.Duration
+		result RateLimitResult
+	}{
+		{"a", 0, RateLimitResult{Allow: true, Limit: 3, Remaining: 2, Reset: 500 * time.Millisecond}},
+		{"a", 0, RateLimitResult{Allow: true, Limit: 3, Remaining: 1, Reset: time.Second}},
+		{"a", 0, RateLimitResult{Allow: true, Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond}},
+		{"a", 0, RateLimitResult{Allow: false, Limit: 3, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: 1500 * time.Millisecond}},
+		{"b", 0, RateLimitResult{Allow: true, Limit: 3, Remaining: 2, Reset: 500 * time.Millisecond}},
+		{"a", 500 * time.Millisecond, RateLimitResult{Allow: true, Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond}},
+		{"a", 10 * time.Second, RateLimitResult{Allow: true, Limit: 3, Remaining: 2, Reset: 500 * time.Millisecond}},
+	}
+	for index, singleTestCase := range testCase {
+		now = now.Add(singleTestCase.after)
+		result := rateLimit.MustTake(singleTestCase.key, rule)
+		AssertEqual(t, result, singleTestCase.result, index)
+	}
+
+	result := rateLimit.MustTake("a", RateLimitRule{})
+	AssertEqual(t, result, RateLimitResult{Allow: true})
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	testCase := []struct {
+		config  RateLimitConfig
+		isError bool
+	}{
+		{RateLimitConfig{Driver: "memory", Route: []string{"/a 1/1s 2"}}, false},
+		{RateLimitConfig{Driver: "memory", Route: []string{"/a 1"}}, true},
+		{RateLimitConfig{Driver: "memory", Route: []string{"/a x/1s"}}, true},
+		{RateLimitConfig{Driver: "memory", Route: []string{"/a 1/xs"}}, true},
+		{RateLimitConfig{Driver: "memory", Rate: -1}, true},
+		{RateLimitConfig{Driver: "redis"}, true},
+		{RateLimitConfig{Driver: "mysql"}, true},
+	}
+	for index, singleTestCase := range testCase {
+		_, err := NewRateLimit(singleTestCase.config)
+		AssertEqual(t, err != nil, singleTestCase.isError, index)
+	}
+}
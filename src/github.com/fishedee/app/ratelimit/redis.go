@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type redisRateLimitStore struct {
+	redisPool *redis.Pool
+}
+
+var redisRateLimitScript = redis.NewScript(1, `
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'time')
+local tokens = tonumber(data[1])
+local last = tonumber(data[2])
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) * rate / period)
+	last = now
+end
+local allow = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allow = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'time', tostring(last))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * period / rate) + 1000)
+return {allow, tostring(tokens)}
+`)
+
+func newRedisRateLimitStore(savePath string) (*redisRateLimitStore, error) {
+	//savePath的格式与queue一致：地址,连接池大小,密码,数据库
+	configs := strings.Split(savePath, ",")
+	address := configs[0]
+	poolSize := 100
+	password := ""
+	dbNum := 0
+	if len(configs) > 1 {
+		if size, err := strconv.Atoi(configs[1]); err == nil && size > 0 {
+			poolSize = size
+		}
+	}
+	if len(configs) > 2 {
+		password = configs[2]
+	}
+	if len(configs) > 3 {
+		if num, err := strconv.Atoi(configs[3]); err == nil && num >= 0 {
+			dbNum = num
+		}
+	}
+	pool := &redis.Pool{
+		MaxIdle:     poolSize,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			c, err := redis.DialTimeout("tcp", address, time.Second, time.Second*12, time.Second)
+			if err != nil {
+				return nil, err
+			}
+			if password != "" {
+				if _, err := c.Do("AUTH", password); err != nil {
+					c.Close()
+					return nil, err
+				}
+			}
+			if _, err := c.Do("SELECT", dbNum); err != nil {
+				c.Close()
+				return nil, err
+			}
+			return c, nil
+		},
+	}
+	conn := pool.Get()
+	defer conn.Close()
+	if conn.Err() != nil {
+		return nil, conn.Err()
+	}
+	return &redisRateLimitStore{
+		redisPool: pool,
+	}, nil
+}
+
+func (this *redisRateLimitStore) take(key string, rule RateLimitRule, now time.Time) (bool, float64, error) {
+	conn := this.redisPool.Get()
+	defer conn.Close()
+
+	//令牌桶的读取与扣减需要是原子的，时间由调用方传入，避免脚本里面调用TIME
+	result, err := redis.Values(redisRateLimitScript.Do(
+		conn,
+		key,
+		rule.Burst,
+		rule.Rate,
+		int64(rule.Period/time.Millisecond),
+		now.UnixNano()/int64(time.Millisecond),
+	))
+	if err != nil {
+		return false, 0, err
+	}
+	allow, err := redis.Int(result[0], nil)
+	if err != nil {
+		return false, 0, err
+	}
+	tokens, err := redis.Float64(result[1], nil)
+	if err != nil {
+		return false, 0, err
+	}
+	return allow == 1, tokens, nil
+}
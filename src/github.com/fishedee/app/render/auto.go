@@ -0,0 +1,144 @@
+package render
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type AutoFormatter struct {
+	formatter map[string]RenderFormatter
+}
+
+var renderMediaType = map[string][]string{
+	"json": {"application/json"},
+	"xml":  {"application/xml", "text/xml"},
+	"csv":  {"text/csv"},
+	"xlsx": {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	"html": {"text/html"},
+	"text": {"text/plain"},
+}
+
+type renderAccept struct {
+	mediaType string
+	quality   float64
+}
+
+func getRenderAccept(accept string) []renderAccept {
+	result := []renderAccept{}
+	for _, single := range strings.Split(accept, ",") {
+		fields := strings.Split(single, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				value, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					quality = value
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		result = append(result, renderAccept{
+			mediaType: mediaType,
+			quality:   quality,
+		})
+	}
+	//同样权重时越具体的类型越优先
+	sort.SliceStable(result, func(i int, j int) bool {
+		if result[i].quality != result[j].quality {
+			return result[i].quality > result[j].quality
+		}
+		return strings.Count(result[i].mediaType, "*") < strings.Count(result[j].mediaType, "*")
+	})
+	return result
+}
+
+func isRenderMediaTypeMatch(accept string, mediaType string) bool {
+	if accept == "*/*" || accept == mediaType {
+		return true
+	}
+	if strings.HasSuffix(accept, "/*") {
+		return strings.HasPrefix(mediaType, accept[0:len(accept)-1])
+	}
+	return false
+}
+
+func NegotiateFormat(r *http.Request, name ...string) string {
+	//按Accept头选择formatter，没有Accept或者都不匹配时使用第一个
+	if len(name) == 0 {
+		return ""
+	}
+	for _, accept := range getRenderAccept(r.Header.Get("Accept")) {
+		for _, singleName := range name {
+			//通配符只匹配formatter的首选类型，避免text/*选中text/xml
+			for mediaTypeIndex, mediaType := range renderMediaType[singleName] {
+				if mediaTypeIndex != 0 && strings.HasSuffix(accept.mediaType, "*") {
+					break
+				}
+				if isRenderMediaTypeMatch(accept.mediaType, mediaType) {
+					return singleName
+				}
+			}
+		}
+	}
+	return name[0]
+}
+
+func GetAutoFormat(r *http.Request, data interface{}) (string, interface{}) {
+	//只有表格数据才会输出为csv与xlsx，输出为json与xml时只保留表格的数据
+	if isRenderTable(data) == false {
+		return NegotiateFormat(r, "json", "xml"), data
+	}
+	name := NegotiateFormat(r, "json", "xml", "csv", "xlsx")
+	if name == "json" || name == "xml" {
+		if table, isOk := data.(RenderTable); isOk {
+			data = table.Data
+		} else if table, isOk := data.(*RenderTable); isOk {
+			data = table.Data
+		}
+	}
+	return name, data
+}
+
+func isRenderTable(data interface{}) bool {
+	switch data.(type) {
+	case [][]string, RenderTable:
+		return true
+	case *RenderTable:
+		return data.(*RenderTable) != nil
+	}
+	return false
+}
+
+func (this *AutoFormatter) Name() string {
+	return "auto"
+}
+
+func (this *AutoFormatter) Format(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	name, data := GetAutoFormat(r, data)
+	w.Header().Add("Vary", "Accept")
+	formatter, isExist := this.formatter[name]
+	if isExist == false {
+		return errors.New("dos not exist formatter " + name)
+	}
+	return formatter.Format(w, r, data)
+}
+
+func NewAutoFormatter(formatter ...RenderFormatter) (*AutoFormatter, error) {
+	result := &AutoFormatter{
+		formatter: map[string]RenderFormatter{},
+	}
+	for _, single := range formatter {
+		result.formatter[single.Name()] = single
+	}
+	return result, nil
+}
@@ -0,0 +1,34 @@
+package render
+
+import (
+	. "github.com/fishedee/encoding"
+	"net/http"
+)
+
+type CsvFormatter struct {
+}
+
+func (this *CsvFormatter) Name() string {
+	return "csv"
+}
+
+func (this *CsvFormatter) Format(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	table, fileName, err := getRenderTable("csv", data)
+	if err != nil {
+		return err
+	}
+	result, err := EncodeCsv(table)
+	if err != nil {
+		return err
+	}
+	setRenderTableHeader(w, "text/csv; charset=utf-8", fileName, ".csv")
+	_, err = w.Write(result)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func NewCsvFormatter() (*CsvFormatter, error) {
+	return &CsvFormatter{}, nil
+}
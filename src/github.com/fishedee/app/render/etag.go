@@ -0,0 +1,161 @@
+package render
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ETagFormatter struct {
+	formatter RenderFormatter
+	weak      bool
+}
+
+type etagWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	buffer      bytes.Buffer
+}
+
+func (this *etagWriter) Header() http.Header {
+	return this.header
+}
+
+func (this *etagWriter) WriteHeader(status int) {
+	if this.wroteHeader {
+		return
+	}
+	this.wroteHeader = true
+	this.status = status
+}
+
+func (this *etagWriter) Write(data []byte) (int, error) {
+	if this.wroteHeader == false {
+		this.WriteHeader(http.StatusOK)
+	}
+	return this.buffer.Write(data)
+}
+
+func (this *ETagFormatter) Name() string {
+	return this.formatter.Name()
+}
+
+func (this *ETagFormatter) getETag(tag string) string {
+	if this.weak {
+		return "W/\"" + tag + "\""
+	}
+	return "\"" + tag + "\""
+}
+
+func isETagMatch(ifNoneMatch string, etag string) bool {
+	//If-None-Match使用弱比较，忽略W/前缀
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, single := range strings.Split(ifNoneMatch, ",") {
+		single = strings.TrimSpace(single)
+		if single == "*" || strings.TrimPrefix(single, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *ETagFormatter) formatFile(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	//文件用大小与修改时间作为ETag，If-Modified-Since，If-None-Match与If-Range交给ServeContent处理
+	file, err := getRenderFile(data)
+	if err != nil {
+		return err
+	}
+	var modTime time.Time
+	var size int64
+	if file.Stream != nil {
+		//生成的内容没有ETag
+		return this.formatter.Format(w, r, data)
+	} else if file.Reader != nil {
+		if file.ModTime.IsZero() {
+			return this.formatter.Format(w, r, data)
+		}
+		size, err = file.Reader.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		_, err = file.Reader.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		modTime = file.ModTime
+	} else {
+		fileInfo, err := os.Stat(file.Path)
+		if err != nil {
+			return err
+		}
+		modTime = fileInfo.ModTime()
+		size = fileInfo.Size()
+	}
+	tag := strconv.FormatInt(modTime.UnixNano(), 36) + "-" + strconv.FormatInt(size, 36)
+	w.Header().Set("ETag", this.getETag(tag))
+	return this.formatter.Format(w, r, data)
+}
+
+func (this *ETagFormatter) Format(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return this.formatter.Format(w, r, data)
+	}
+	if _, isOk := this.formatter.(*FileFormatter); isOk {
+		return this.formatFile(w, r, data)
+	}
+
+	//先输出到缓冲里面，根据内容计算ETag
+	writer := &etagWriter{
+		header: w.Header(),
+	}
+	err := this.formatter.Format(writer, r, data)
+	if err != nil {
+		return err
+	}
+	if writer.wroteHeader == false {
+		writer.WriteHeader(http.StatusOK)
+	}
+	if writer.status != http.StatusOK {
+		w.WriteHeader(writer.status)
+		_, err = w.Write(writer.buffer.Bytes())
+		return err
+	}
+	hash := sha1.Sum(writer.buffer.Bytes())
+	etag := this.getETag(base64.RawURLEncoding.EncodeToString(hash[:]))
+	header := w.Header()
+	header.Set("ETag", etag)
+	if strings.Contains(strings.Join(header["Cache-Control"], ","), "no-store") {
+		//no-store会让客户端丢弃响应，改为每次都需要验证
+		header.Set("Cache-Control", "private, no-cache")
+		header.Del("Pragma")
+	}
+	if isETagMatch(r.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == "HEAD" {
+		return nil
+	}
+	_, err = w.Write(writer.buffer.Bytes())
+	return err
+}
+
+func NewETagFormatter(formatter RenderFormatter, weak bool) (*ETagFormatter, error) {
+	return &ETagFormatter{
+		formatter: formatter,
+		weak:      weak,
+	}, nil
+}
@@ -2,35 +2,158 @@ package render
 
 import (
 	"errors"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path"
+	"strings"
+	"time"
 )
 
+type RenderFile struct {
+	Path        string
+	Reader      io.ReadSeeker
+	Stream      func(w io.Writer) error
+	FileName    string
+	Inline      bool
+	ContentType string
+	ModTime     time.Time
+}
+
 type FileFormatter struct {
 }
 
+type fileStreamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (this *fileStreamWriter) Write(data []byte) (int, error) {
+	//每次写完都推送给客户端，响应体不会堆积在内存里面
+	n, err := this.w.Write(data)
+	if err != nil {
+		return n, err
+	}
+	if this.flusher != nil {
+		this.flusher.Flush()
+	}
+	return n, nil
+}
+
+func getContentDisposition(dispositionType string, fileName string) string {
+	//filename给不支持RFC 5987的客户端使用，非ASCII字符替换为下划线，filename*保留UTF-8的原名
+	if fileName == "" {
+		return dispositionType
+	}
+	fileName = path.Base(strings.Replace(fileName, "\\", "/", -1))
+	isAscii := true
+	fallback := []rune{}
+	for _, single := range fileName {
+		if single < 0x20 || single >= 0x7f || single == '"' {
+			isAscii = false
+			fallback = append(fallback, '_')
+		} else {
+			fallback = append(fallback, single)
+		}
+	}
+	result := dispositionType + "; filename=\"" + string(fallback) + "\""
+	if isAscii {
+		return result
+	}
+	encode := []byte{}
+	for _, single := range []byte(fileName) {
+		if (single >= 'a' && single <= 'z') || (single >= 'A' && single <= 'Z') || (single >= '0' && single <= '9') ||
+			strings.IndexByte("!#$&+-.^_`|~", single) != -1 {
+			encode = append(encode, single)
+		} else {
+			encode = append(encode, '%', "0123456789ABCDEF"[single>>4], "0123456789ABCDEF"[single&15])
+		}
+	}
+	return result + "; filename*=UTF-8''" + string(encode)
+}
+
+func getRenderFile(data interface{}) (RenderFile, error) {
+	if fileName, isOk := data.(string); isOk {
+		return RenderFile{Path: fileName}, nil
+	} else if file, isOk := data.(RenderFile); isOk {
+		return file, nil
+	} else if file, isOk := data.(*RenderFile); isOk && file != nil {
+		return *file, nil
+	}
+	return RenderFile{}, errors.New("invalid data type for file formatter")
+}
+
+func (this *FileFormatter) setHeader(w http.ResponseWriter, file RenderFile) {
+	if file.ContentType != "" {
+		w.Header().Set("Content-Type", file.ContentType)
+	}
+	if file.FileName != "" {
+		dispositionType := "attachment"
+		if file.Inline {
+			dispositionType = "inline"
+		}
+		w.Header().Set("Content-Disposition", getContentDisposition(dispositionType, file.FileName))
+	}
+}
+
+func (this *FileFormatter) formatStream(w http.ResponseWriter, r *http.Request, file RenderFile) error {
+	//生成的内容长度未知，不支持Range，使用chunked传输
+	if file.ContentType == "" {
+		file.ContentType = mime.TypeByExtension(path.Ext(file.FileName))
+		if file.ContentType == "" {
+			file.ContentType = "application/octet-stream"
+		}
+	}
+	this.setHeader(w, file)
+	w.Header().Del("Content-Length")
+	w.Header().Set("Accept-Ranges", "none")
+	if r.Method == "HEAD" {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	writer := &fileStreamWriter{w: w}
+	writer.flusher, _ = w.(http.Flusher)
+	return file.Stream(writer)
+}
+
 func (this *FileFormatter) Name() string {
 	return "file"
 }
 
 func (this *FileFormatter) Format(w http.ResponseWriter, r *http.Request, data interface{}) error {
-	var result string
-	if fileName, isOk := data.(string); isOk == true {
-		result = fileName
-	} else {
-		return errors.New("invalid data type for file formatter")
-	}
-	file, err := os.Open(result)
+	file, err := getRenderFile(data)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return err
+	if file.Stream != nil {
+		return this.formatStream(w, r, file)
+	}
+
+	//Range，If-Range与多段的206响应交给ServeContent处理，Content-Type按文件的后缀名推断
+	name := file.FileName
+	reader := file.Reader
+	modTime := file.ModTime
+	if reader == nil {
+		osFile, err := os.Open(file.Path)
+		if err != nil {
+			return err
+		}
+		defer osFile.Close()
+		fileInfo, err := osFile.Stat()
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = file.Path
+		}
+		reader = osFile
+		modTime = fileInfo.ModTime()
+	} else if closer, isOk := reader.(io.Closer); isOk {
+		defer closer.Close()
 	}
-	http.ServeContent(w, r, path.Ext(result), fileInfo.ModTime(), file)
+	this.setHeader(w, file)
+	http.ServeContent(w, r, path.Ext(name), modTime, reader)
 	return nil
 }
 
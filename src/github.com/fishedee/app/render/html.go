@@ -1,21 +1,56 @@
 package render
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
+	. "github.com/fishedee/app/csrf"
+	"html/template"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"text/template"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+type HtmlFormatterConfig struct {
+	Dir      string
+	AssetDir string
+	Reload   bool
+	FuncMap  map[string]interface{}
+}
+
+type htmlTemplate struct {
+	master *template.Template
+	pool   sync.Pool
+}
+
+type htmlTemplateSet struct {
+	modTime map[string]time.Time
+	base    *htmlTemplate
+	page    map[string]*htmlTemplate
+}
+
+type htmlAsset struct {
+	modTime time.Time
+	size    int64
+	version string
+}
+
 type HtmlFormatter struct {
-	tmpl *template.Template
+	config    HtmlFormatterConfig
+	set       atomic.Value
+	loadMutex sync.Mutex
+	asset     sync.Map
 }
 
-func (this *HtmlFormatter) load(dir string) error {
-	fileList := []string{}
-	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+func (this *HtmlFormatter) getFileList() (map[string]time.Time, error) {
+	fileList := map[string]time.Time{}
+	err := filepath.Walk(this.config.Dir, func(path string, f os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -25,17 +60,203 @@ func (this *HtmlFormatter) load(dir string) error {
 		if strings.HasSuffix(f.Name(), ".html") == false {
 			return nil
 		}
-		fileList = append(fileList, path)
+		fileList[path] = f.ModTime()
 		return nil
 	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return fileList, nil
+}
+
+func (this *HtmlFormatter) getFuncMap() template.FuncMap {
+	funcMap := template.FuncMap{}
+	for name, function := range this.config.FuncMap {
+		funcMap[name] = function
+	}
+	funcMap["url"] = this.url
+	funcMap["asset"] = this.getAsset
+	funcMap["csrfToken"] = func() string { return "" }
+	funcMap["csrfField"] = func() template.HTML { return "" }
+	return funcMap
+}
+
+func (this *HtmlFormatter) getRequestFuncMap(r *http.Request) template.FuncMap {
+	//csrf的token每个请求都不同，每次执行之前重新绑定
+	token := GetCsrfToken(r.Context())
+	field := template.HTML(GetCsrfField(r.Context()))
+	//url使用处理当前请求的Router生成，热更新路由时新旧Router互不影响
+	var url RenderUrlBuilder = this.url
+	if builder := GetRenderUrlBuilder(r.Context()); builder != nil {
+		url = builder
+	}
+	return template.FuncMap{
+		"url":       url,
+		"csrfToken": func() string { return token },
+		"csrfField": func() template.HTML { return field },
+	}
+}
+
+func newHtmlTemplate(master *template.Template) *htmlTemplate {
+	return &htmlTemplate{
+		master: master,
+	}
+}
+
+func (this *htmlTemplate) get() (*template.Template, error) {
+	//执行过的html/template不能再Clone，保留一份不执行的，复制出来的放进池子里面复用
+	//每个副本同一时间只给一个请求使用，可以绑定请求相关的函数
+	tmpl, isOk := this.pool.Get().(*template.Template)
+	if isOk {
+		return tmpl, nil
+	}
+	return this.master.Clone()
+}
+
+func (this *htmlTemplate) put(tmpl *template.Template) {
+	this.pool.Put(tmpl)
+}
+
+func (this *HtmlFormatter) load(fileList map[string]time.Time) (*htmlTemplateSet, error) {
+	pathList := []string{}
+	for path := range fileList {
+		pathList = append(pathList, path)
+	}
+	sort.Strings(pathList)
+
+	//所有文件按文件名放在同一个集合里面，可以互相引用
+	//layout目录下的文件连同define一起共享，其他页面只共享自身，页面的define只在自己的集合里面生效
+	funcMap := this.getFuncMap()
+	base := template.New("").Funcs(funcMap)
+	content := map[string]string{}
+	for _, path := range pathList {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		content[path] = string(data)
+		name := filepath.Base(path)
+		if this.isLayout(path) {
+			_, err = base.New(name).Parse(content[path])
+		} else {
+			var page *template.Template
+			page, err = template.New(name).Funcs(funcMap).Parse(content[path])
+			if err == nil {
+				_, err = base.AddParseTree(name, page.Lookup(name).Tree)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//每个页面单独复制一份集合并重新解析自身，页面的define覆盖布局的block
+	set := &htmlTemplateSet{
+		modTime: fileList,
+		page:    map[string]*htmlTemplate{},
+	}
+	for _, path := range pathList {
+		if this.isLayout(path) {
+			continue
+		}
+		page, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		_, err = page.New(filepath.Base(path)).Parse(content[path])
+		if err != nil {
+			return nil, err
+		}
+		set.page[filepath.Base(path)] = newHtmlTemplate(page)
+	}
+	set.base = newHtmlTemplate(base)
+	return set, nil
+}
+
+func (this *HtmlFormatter) isLayout(path string) bool {
+	rel, err := filepath.Rel(this.config.Dir, filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	for _, single := range strings.Split(filepath.ToSlash(rel), "/") {
+		if single == "layout" {
+			return true
+		}
+	}
+	return false
+}
+
+func isHtmlFileListEqual(a map[string]time.Time, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, modTime := range a {
+		if modTime.Equal(b[path]) == false {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *HtmlFormatter) getTemplateSet() (*htmlTemplateSet, error) {
+	set := this.set.Load().(*htmlTemplateSet)
+	if this.config.Reload == false {
+		return set, nil
+	}
+	//开发模式下每次请求检查文件的修改时间，有变化时重新解析
+	fileList, err := this.getFileList()
+	if err != nil {
+		return nil, err
+	}
+	if isHtmlFileListEqual(fileList, set.modTime) {
+		return set, nil
+	}
+	this.loadMutex.Lock()
+	defer this.loadMutex.Unlock()
+	set = this.set.Load().(*htmlTemplateSet)
+	if isHtmlFileListEqual(fileList, set.modTime) {
+		return set, nil
 	}
-	this.tmpl, err = template.ParseFiles(fileList...)
+	set, err = this.load(fileList)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	this.set.Store(set)
+	return set, nil
+}
+
+func (this *HtmlFormatter) url(name string, params ...interface{}) (string, error) {
+	return "", errors.New("url builder has not been set")
+}
+
+func (this *HtmlFormatter) getAsset(path string) (string, error) {
+	//静态文件的url后面加上内容的摘要，文件变化以后浏览器缓存自动失效
+	if this.config.AssetDir == "" {
+		return path, nil
+	}
+	fileName := filepath.Join(this.config.AssetDir, filepath.FromSlash(path))
+	fileInfo, err := os.Stat(fileName)
+	if err != nil {
+		return "", err
+	}
+	var version string
+	cache, isOk := this.asset.Load(fileName)
+	if isOk && cache.(htmlAsset).modTime.Equal(fileInfo.ModTime()) && cache.(htmlAsset).size == fileInfo.Size() {
+		version = cache.(htmlAsset).version
+	} else {
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return "", err
+		}
+		hash := md5.Sum(data)
+		version = hex.EncodeToString(hash[:])[0:8]
+		this.asset.Store(fileName, htmlAsset{
+			modTime: fileInfo.ModTime(),
+			size:    fileInfo.Size(),
+			version: version,
+		})
+	}
+	return path + "?v=" + version, nil
 }
 
 func (this *HtmlFormatter) Name() string {
@@ -48,7 +269,21 @@ func (this *HtmlFormatter) Format(w http.ResponseWriter, r *http.Request, data i
 	if dataArray, isOk := data.([]interface{}); isOk == true {
 		fileName := dataArray[0].(string)
 		fileData := dataArray[1]
-		err := this.tmpl.ExecuteTemplate(w, fileName, fileData)
+		set, err := this.getTemplateSet()
+		if err != nil {
+			return err
+		}
+		page, isExist := set.page[fileName]
+		if isExist == false {
+			page = set.base
+		}
+		tmpl, err := page.get()
+		if err != nil {
+			return err
+		}
+		defer page.put(tmpl)
+		tmpl.Funcs(this.getRequestFuncMap(r))
+		err = tmpl.ExecuteTemplate(w, fileName, fileData)
 		if err != nil {
 			return err
 		}
@@ -59,10 +294,23 @@ func (this *HtmlFormatter) Format(w http.ResponseWriter, r *http.Request, data i
 }
 
 func NewHtmlFormatter(dir string) (*HtmlFormatter, error) {
-	htmlFormatter := &HtmlFormatter{}
-	err := htmlFormatter.load(dir)
+	return NewHtmlFormatterWithConfig(HtmlFormatterConfig{
+		Dir: dir,
+	})
+}
+
+func NewHtmlFormatterWithConfig(config HtmlFormatterConfig) (*HtmlFormatter, error) {
+	htmlFormatter := &HtmlFormatter{
+		config: config,
+	}
+	fileList, err := htmlFormatter.getFileList()
+	if err != nil {
+		return nil, err
+	}
+	set, err := htmlFormatter.load(fileList)
 	if err != nil {
 		return nil, err
 	}
+	htmlFormatter.set.Store(set)
 	return htmlFormatter, nil
 }
@@ -14,9 +14,12 @@ func (this *JsonFormatter) Name() string {
 
 func (this *JsonFormatter) Format(w http.ResponseWriter, r *http.Request, data interface{}) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Add("Cache-Control", "private, no-store, no-cache, must-revalidate, max-age=0")
-	w.Header().Add("Cache-Control", "post-check=0, pre-check=0")
-	w.Header().Set("Pragma", "no-cache")
+	//handler或者中间件已经指定了缓存策略时不覆盖
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Add("Cache-Control", "private, no-store, no-cache, must-revalidate, max-age=0")
+		w.Header().Add("Cache-Control", "post-check=0, pre-check=0")
+		w.Header().Set("Pragma", "no-cache")
+	}
 
 	encoder := json.NewEncoder(w)
 	encoder.SetEscapeHTML(false)
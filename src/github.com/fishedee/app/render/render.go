@@ -1,9 +1,11 @@
 package render
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type RenderFormatter interface {
@@ -24,8 +26,28 @@ type RenderFactory interface {
 	Create(w http.ResponseWriter, r *http.Request) Render
 }
 
+type RenderUrlBuilder func(name string, params ...interface{}) (string, error)
+
+type renderUrlBuilderKey struct{}
+
+func WithRenderUrlBuilder(ctx context.Context, builder RenderUrlBuilder) context.Context {
+	return context.WithValue(ctx, renderUrlBuilderKey{}, builder)
+}
+
+func GetRenderUrlBuilder(ctx context.Context) RenderUrlBuilder {
+	if ctx == nil {
+		return nil
+	}
+	builder, _ := ctx.Value(renderUrlBuilderKey{}).(RenderUrlBuilder)
+	return builder
+}
+
 type RenderConfig struct {
-	TemplateDir string `config:"templatedir"`
+	TemplateDir  string        `config:"templatedir"`
+	AssetDir     string        `config:"assetdir"`
+	RunMode      string        `config:"runmode"`
+	ETag         string        `config:"etag"`
+	SseHeartbeat time.Duration `config:"sseheartbeat"`
 }
 
 type renderFactoryImplement struct {
@@ -33,6 +55,10 @@ type renderFactoryImplement struct {
 }
 
 func NewRenderFactory(config RenderConfig) (RenderFactory, error) {
+	return NewRenderFactoryWithFuncMap(config, nil)
+}
+
+func NewRenderFactoryWithFuncMap(config RenderConfig, funcMap map[string]interface{}) (RenderFactory, error) {
 	impl := &renderFactoryImplement{
 		formatter: map[string]RenderFormatter{},
 	}
@@ -53,20 +79,66 @@ func NewRenderFactory(config RenderConfig) (RenderFactory, error) {
 		func() (RenderFormatter, error) {
 			return NewFileFormatter()
 		},
+		func() (RenderFormatter, error) {
+			return NewXmlFormatter()
+		},
+		func() (RenderFormatter, error) {
+			return NewCsvFormatter()
+		},
+		func() (RenderFormatter, error) {
+			return NewXlsxFormatter()
+		},
+		func() (RenderFormatter, error) {
+			//sse默认每15秒发送一次心跳
+			heartbeat := config.SseHeartbeat
+			if heartbeat == 0 {
+				heartbeat = 15 * time.Second
+			}
+			return NewSseFormatter(heartbeat)
+		},
 	}
 	if config.TemplateDir != "" {
 		preFormatter = append(preFormatter, func() (RenderFormatter, error) {
-			return NewHtmlFormatter(config.TemplateDir)
+			//开发模式下模板修改以后不需要重启
+			return NewHtmlFormatterWithConfig(HtmlFormatterConfig{
+				Dir:      config.TemplateDir,
+				AssetDir: config.AssetDir,
+				Reload:   config.RunMode == "dev",
+				FuncMap:  funcMap,
+			})
 		})
 	}
 
+	if config.ETag != "" && config.ETag != "strong" && config.ETag != "weak" {
+		return nil, errors.New("invalid render etag " + config.ETag)
+	}
 	for _, singlePreFormatter := range preFormatter {
 		formatter, err := singlePreFormatter()
 		if err != nil {
 			return nil, err
 		}
+		//ETag是可选的，只用于json，text与file
+		name := formatter.Name()
+		if config.ETag != "" && (name == "json" || name == "text" || name == "file") {
+			formatter, err = NewETagFormatter(formatter, config.ETag == "weak")
+			if err != nil {
+				return nil, err
+			}
+		}
 		impl.RegisterFormatter(formatter)
 	}
+
+	//auto按Accept头在json，xml，csv与xlsx之间选择
+	autoFormatter, err := NewAutoFormatter(
+		impl.formatter["json"],
+		impl.formatter["xml"],
+		impl.formatter["csv"],
+		impl.formatter["xlsx"],
+	)
+	if err != nil {
+		return nil, err
+	}
+	impl.RegisterFormatter(autoFormatter)
 	return impl, nil
 }
 
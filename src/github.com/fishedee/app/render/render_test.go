@@ -1,11 +1,20 @@
 package render
 
 import (
+	"context"
+	"fmt"
+	. "github.com/fishedee/app/csrf"
 	. "github.com/fishedee/assert"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRenderBasic(t *testing.T) {
@@ -64,3 +73,413 @@ func TestRenderRedirect(t *testing.T) {
 		AssertEqual(t, result.StatusCode, singleTestCase.code, index)
 	}
 }
+
+func TestRenderHtmlUrl(t *testing.T) {
+	renderFactory, err := NewRenderFactory(RenderConfig{TemplateDir: "testdata"})
+	if err != nil {
+		panic(err)
+	}
+	data := []interface{}{"url.html", map[string]interface{}{"UserId": 10001}}
+
+	r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+	w := httptest.NewRecorder()
+	err = renderFactory.Create(w, r).Format("html", data)
+	AssertEqual(t, err != nil, true)
+
+	r2 := r.WithContext(WithRenderUrlBuilder(r.Context(), func(name string, params ...interface{}) (string, error) {
+		return fmt.Sprintf("/%v/%v", name, params[1]), nil
+	}))
+	w2 := httptest.NewRecorder()
+	err = renderFactory.Create(w2, r2).Format("html", data)
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w2.Body.String(), "<a href=\"/user.detail/10001\">detail</a>")
+}
+
+func TestRenderHtmlCsrf(t *testing.T) {
+	renderFactory, err := NewRenderFactory(RenderConfig{TemplateDir: "testdata"})
+	if err != nil {
+		panic(err)
+	}
+	data := []interface{}{"csrf.html", nil}
+
+	r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+	w := httptest.NewRecorder()
+	err = renderFactory.Create(w, r).Format("html", data)
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w.Body.String(), "<form></form>")
+
+	r2 := r.WithContext(WithCsrfToken(r.Context(), "_csrf", "abc"))
+	w2 := httptest.NewRecorder()
+	err = renderFactory.Create(w2, r2).Format("html", data)
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w2.Body.String(), "<form><input type=\"hidden\" name=\"_csrf\" value=\"abc\"></form>abc")
+
+	//复用的模板副本不能带上一个请求的token
+	w3 := httptest.NewRecorder()
+	err = renderFactory.Create(w3, r).Format("html", data)
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w3.Body.String(), "<form></form>")
+
+	//并发的请求各自使用自己的token
+	var wg sync.WaitGroup
+	for i := 0; i != 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token := strconv.Itoa(i)
+			r4 := r.WithContext(WithCsrfToken(r.Context(), "_csrf", token))
+			w4 := httptest.NewRecorder()
+			err := renderFactory.Create(w4, r4).Format("html", data)
+			AssertEqual(t, err, nil)
+			AssertEqual(t, w4.Body.String(), "<form><input type=\"hidden\" name=\"_csrf\" value=\""+token+"\"></form>"+token)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRenderETag(t *testing.T) {
+	testCase := []struct {
+		etag        string
+		method      string
+		name        string
+		data        interface{}
+		ifNoneMatch bool
+		status      int
+		output      string
+		isWeak      bool
+	}{
+		{"strong", "GET", "json", map[string]string{"a": "1"}, false, 200, "{\"a\":\"1\"}\n", false},
+		{"strong", "GET", "json", map[string]string{"a": "1"}, true, 304, "", false},
+		{"weak", "GET", "text", "456", false, 200, "456", true},
+		{"weak", "GET", "text", "456", true, 304, "", true},
+		{"strong", "GET", "file", "testdata/index.html", false, 200, "Hello {{.Name}}", false},
+		{"strong", "GET", "file", "testdata/index.html", true, 304, "", false},
+		{"strong", "POST", "text", "456", false, 200, "456", false},
+		{"", "GET", "text", "456", false, 200, "456", false},
+	}
+	for index, singleTestCase := range testCase {
+		renderFactory, err := NewRenderFactory(RenderConfig{ETag: singleTestCase.etag})
+		AssertEqual(t, err, nil, index)
+
+		//先请求一次拿到ETag
+		r, _ := http.NewRequest(singleTestCase.method, "http://www.baidu.com/", nil)
+		w := httptest.NewRecorder()
+		err = renderFactory.Create(w, r).Format(singleTestCase.name, singleTestCase.data)
+		AssertEqual(t, err, nil, index)
+		etag := w.Header().Get("ETag")
+		if singleTestCase.etag == "" || singleTestCase.method != "GET" {
+			AssertEqual(t, etag, "", index)
+		} else {
+			AssertEqual(t, etag != "", true, index)
+			AssertEqual(t, etag[0:2] == "W/", singleTestCase.isWeak, index)
+		}
+
+		r2, _ := http.NewRequest(singleTestCase.method, "http://www.baidu.com/", nil)
+		if singleTestCase.ifNoneMatch {
+			r2.Header.Set("If-None-Match", "\"other\", "+etag)
+		} else {
+			r2.Header.Set("If-None-Match", "\"other\"")
+		}
+		w2 := httptest.NewRecorder()
+		err = renderFactory.Create(w2, r2).Format(singleTestCase.name, singleTestCase.data)
+		AssertEqual(t, err, nil, index)
+		AssertEqual(t, w2.Code, singleTestCase.status, index)
+		AssertEqual(t, w2.Body.String(), singleTestCase.output, index)
+	}
+
+	//json开启ETag以后允许客户端缓存响应
+	renderFactory, _ := NewRenderFactory(RenderConfig{ETag: "strong"})
+	r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+	w := httptest.NewRecorder()
+	renderFactory.Create(w, r).Format("json", 1)
+	AssertEqual(t, w.Header()["Cache-Control"], []string{"private, no-cache"})
+
+	_, err := NewRenderFactory(RenderConfig{ETag: "abc"})
+	AssertEqual(t, err != nil, true)
+}
+
+func TestRenderTable(t *testing.T) {
+	type user struct {
+		UserId int    `json:"userId"`
+		Name   string `json:"name"`
+	}
+	users := []user{{1, "fish"}, {2, "cat"}}
+	table := RenderTable{
+		FileName: "用户",
+		Column:   map[string]string{"userId": "ID", "name": "名称"},
+		Data:     users,
+	}
+	testCase := []struct {
+		name        string
+		accept      string
+		data        interface{}
+		output      string
+		contentType string
+		disposition string
+	}{
+		{"xml", "", map[string]interface{}{"code": 0, "data": []string{"a", "b"}}, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><code>0</code><data>a</data><data>b</data></response>", "application/xml; charset=utf-8", ""},
+		{"xml", "", []user{{1, "fish"}}, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><item><UserId>1</UserId><Name>fish</Name></item></response>", "application/xml; charset=utf-8", ""},
+		{"csv", "", table, "\xEF\xBB\xBF名称,ID\nfish,1\ncat,2\n", "text/csv; charset=utf-8", "attachment; filename=\"__.csv\"; filename*=UTF-8''%E7%94%A8%E6%88%B7.csv"},
+		{"csv", "", [][]string{{"a", "b"}}, "\xEF\xBB\xBFa,b\n", "text/csv; charset=utf-8", "attachment"},
+		{"auto", "", table, "[{\"userId\":1,\"name\":\"fish\"},{\"userId\":2,\"name\":\"cat\"}]\n", "application/json; charset=utf-8", ""},
+		{"auto", "text/csv", table, "\xEF\xBB\xBF名称,ID\nfish,1\ncat,2\n", "text/csv; charset=utf-8", "attachment; filename=\"__.csv\"; filename*=UTF-8''%E7%94%A8%E6%88%B7.csv"},
+		{"auto", "text/html;q=0.9, application/xml", "abc", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<string>abc</string>", "application/xml; charset=utf-8", ""},
+		{"auto", "text/csv", "abc", "\"abc\"\n", "application/json; charset=utf-8", ""},
+		{"auto", "application/json;q=0.5, text/*", table, "\xEF\xBB\xBF名称,ID\nfish,1\ncat,2\n", "text/csv; charset=utf-8", "attachment; filename=\"__.csv\"; filename*=UTF-8''%E7%94%A8%E6%88%B7.csv"},
+	}
+	renderFactory, err := NewRenderFactory(RenderConfig{})
+	AssertEqual(t, err, nil)
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+		if singleTestCase.accept != "" {
+			r.Header.Set("Accept", singleTestCase.accept)
+		}
+		w := httptest.NewRecorder()
+		err := renderFactory.Create(w, r).Format(singleTestCase.name, singleTestCase.data)
+		AssertEqual(t, err, nil, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.output, index)
+		AssertEqual(t, w.Header().Get("Content-Type"), singleTestCase.contentType, index)
+		AssertEqual(t, w.Header().Get("Content-Disposition"), singleTestCase.disposition, index)
+	}
+
+	//xlsx输出为zip格式的文件
+	r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+	w := httptest.NewRecorder()
+	err = renderFactory.Create(w, r).Format("xlsx", table)
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w.Body.String()[0:2], "PK")
+	AssertEqual(t, w.Header().Get("Content-Disposition"), "attachment; filename=\"__.xlsx\"; filename*=UTF-8''%E7%94%A8%E6%88%B7.xlsx")
+
+	err = renderFactory.Create(httptest.NewRecorder(), r).Format("csv", "abc")
+	AssertEqual(t, err != nil, true)
+}
+
+func TestRenderHtmlLayout(t *testing.T) {
+	testCase := []struct {
+		name   string
+		data   interface{}
+		output string
+	}{
+		{"page1.html", map[string]interface{}{"Name": "<b>Fish</b>"}, "<html><head><title>Page1</title></head><body><p>&lt;b&gt;Fish&lt;/b&gt;</p></body></html>"},
+		{"page2.html", map[string]interface{}{"Name": "a&b'"}, "<html><head><title>Default</title></head><body><a href=\"/user?name=a%26b%27\" onclick=\"show(&#34;a\\u0026b&#39;&#34;)\">a&amp;b&#39;</a></body></html>"},
+		{"index2.html", map[string]interface{}{"User": "<Fish>"}, "<html><body><div>&lt;Fish&gt;</div></body></html>"},
+	}
+	renderFactory, err := NewRenderFactory(RenderConfig{TemplateDir: "testdata"})
+	AssertEqual(t, err, nil)
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+		w := httptest.NewRecorder()
+		err := renderFactory.Create(w, r).Format("html", []interface{}{singleTestCase.name, singleTestCase.data})
+		AssertEqual(t, err, nil, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.output, index)
+	}
+}
+
+func TestRenderHtmlFuncMap(t *testing.T) {
+	renderFactory, err := NewRenderFactory(RenderConfig{TemplateDir: "testdata", AssetDir: "testdata"})
+	AssertEqual(t, err, nil)
+	r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+	w := httptest.NewRecorder()
+	err = renderFactory.Create(w, r).Format("html", []interface{}{"asset.html", nil})
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w.Body.String(), "<script src=\"/static/app.js?v=cb9f7f8f\"></script>")
+
+	//自定义函数需要在解析模板之前注册
+	dir, err := ioutil.TempDir("", "render")
+	AssertEqual(t, err, nil)
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(dir+"/upper.html", []byte("{{upper .}}"), 0644)
+	AssertEqual(t, err, nil)
+
+	_, err = NewRenderFactory(RenderConfig{TemplateDir: dir})
+	AssertEqual(t, err != nil, true)
+
+	renderFactory, err = NewRenderFactoryWithFuncMap(RenderConfig{TemplateDir: dir}, map[string]interface{}{
+		"upper": strings.ToUpper,
+	})
+	AssertEqual(t, err, nil)
+	w2 := httptest.NewRecorder()
+	err = renderFactory.Create(w2, r).Format("html", []interface{}{"upper.html", "<fish>"})
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w2.Body.String(), "&lt;FISH&gt;")
+}
+
+func TestRenderHtmlReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render")
+	AssertEqual(t, err, nil)
+	defer os.RemoveAll(dir)
+	writeFile := func(name string, data string, modTime time.Time) {
+		err := ioutil.WriteFile(dir+"/"+name, []byte(data), 0644)
+		AssertEqual(t, err, nil)
+		err = os.Chtimes(dir+"/"+name, modTime, modTime)
+		AssertEqual(t, err, nil)
+	}
+	now := time.Now()
+	writeFile("index.html", "Hello {{.}}", now.Add(-time.Minute))
+
+	testCase := []struct {
+		runMode string
+		output  string
+	}{
+		{"dev", "Hi fish"},
+		{"prod", "Hello fish"},
+	}
+	for index, singleTestCase := range testCase {
+		writeFile("index.html", "Hello {{.}}", now.Add(-time.Minute))
+		renderFactory, err := NewRenderFactory(RenderConfig{TemplateDir: dir, RunMode: singleTestCase.runMode})
+		AssertEqual(t, err, nil, index)
+
+		//修改模板以后，只有开发模式会重新解析
+		writeFile("index.html", "Hi {{.}}", now)
+		r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+		w := httptest.NewRecorder()
+		err = renderFactory.Create(w, r).Format("html", []interface{}{"index.html", "fish"})
+		AssertEqual(t, err, nil, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.output, index)
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCase := []struct {
+		etag        string
+		data        interface{}
+		header      map[string]string
+		status      int
+		output      string
+		contentType string
+		disposition string
+	}{
+		{"", "testdata/range.txt", nil, 200, "0123456789", "text/plain; charset=utf-8", ""},
+		{"", "testdata/range.txt", map[string]string{"Range": "bytes=2-4"}, 206, "234", "text/plain; charset=utf-8", ""},
+		{"", RenderFile{Path: "testdata/range.txt", FileName: "报表.txt"}, map[string]string{"Range": "bytes=-3"}, 206, "789", "text/plain; charset=utf-8", "attachment; filename=\"__.txt\"; filename*=UTF-8''%E6%8A%A5%E8%A1%A8.txt"},
+		{"", RenderFile{Path: "testdata/range.txt", FileName: "a b.txt", Inline: true}, map[string]string{"Range": "bytes=20-"}, 416, "", "text/plain; charset=utf-8", "inline; filename=\"a b.txt\""},
+		{"", RenderFile{Reader: strings.NewReader("abcdef"), FileName: "data.csv", ModTime: modTime}, map[string]string{"Range": "bytes=1-2"}, 206, "bc", "text/csv; charset=utf-8", "attachment; filename=\"data.csv\""},
+		{"", RenderFile{Reader: strings.NewReader("abcdef"), ContentType: "video/mp4", ModTime: modTime}, map[string]string{"Range": "bytes=0-1", "If-Range": "Tue, 01 Jan 2019 00:00:00 GMT"}, 200, "abcdef", "video/mp4", ""},
+		{"", RenderFile{Reader: strings.NewReader("abcdef"), ContentType: "video/mp4", ModTime: modTime}, map[string]string{"Range": "bytes=0-1", "If-Range": "Wed, 01 Jan 2020 00:00:00 GMT"}, 206, "ab", "video/mp4", ""},
+		{"strong", RenderFile{Reader: strings.NewReader("abcdef"), ContentType: "video/mp4", ModTime: modTime}, map[string]string{"Range": "bytes=0-1", "If-Range": "\"other\""}, 200, "abcdef", "video/mp4", ""},
+		{"", RenderFile{Stream: func(w io.Writer) error {
+			for i := 0; i != 3; i++ {
+				w.Write([]byte(strconv.Itoa(i)))
+			}
+			return nil
+		}, FileName: "导出.csv"}, map[string]string{"Range": "bytes=0-1"}, 200, "012", "text/csv; charset=utf-8", "attachment; filename=\"__.csv\"; filename*=UTF-8''%E5%AF%BC%E5%87%BA.csv"},
+	}
+	for index, singleTestCase := range testCase {
+		renderFactory, err := NewRenderFactory(RenderConfig{ETag: singleTestCase.etag})
+		AssertEqual(t, err, nil, index)
+		r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+		for key, value := range singleTestCase.header {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		err = renderFactory.Create(w, r).Format("file", singleTestCase.data)
+		AssertEqual(t, err, nil, index)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		if w.Code != 416 {
+			AssertEqual(t, w.Body.String(), singleTestCase.output, index)
+			AssertEqual(t, w.Header().Get("Content-Type"), singleTestCase.contentType, index)
+		}
+		AssertEqual(t, w.Header().Get("Content-Disposition"), singleTestCase.disposition, index)
+	}
+
+	//多段Range返回multipart/byteranges
+	renderFactory, _ := NewRenderFactory(RenderConfig{ETag: "strong"})
+	r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+	w := httptest.NewRecorder()
+	err := renderFactory.Create(w, r).Format("file", "testdata/range.txt")
+	AssertEqual(t, err, nil)
+	etag := w.Header().Get("ETag")
+
+	r.Header.Set("Range", "bytes=0-1,5-6")
+	r.Header.Set("If-Range", etag)
+	w2 := httptest.NewRecorder()
+	err = renderFactory.Create(w2, r).Format("file", "testdata/range.txt")
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w2.Code, 206)
+	AssertEqual(t, strings.HasPrefix(w2.Header().Get("Content-Type"), "multipart/byteranges; boundary="), true)
+	AssertEqual(t, strings.Contains(w2.Body.String(), "Content-Range: bytes 0-1/10\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n01\r\n"), true)
+	AssertEqual(t, strings.Contains(w2.Body.String(), "Content-Range: bytes 5-6/10\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n56\r\n"), true)
+
+	//生成的内容边写边推送，没有Content-Length
+	w3 := httptest.NewRecorder()
+	err = renderFactory.Create(w3, r).Format("file", &RenderFile{Stream: func(w io.Writer) error {
+		_, err := w.Write([]byte("abc"))
+		return err
+	}})
+	AssertEqual(t, err, nil)
+	AssertEqual(t, w3.Flushed, true)
+	AssertEqual(t, w3.Header().Get("Content-Length"), "")
+	AssertEqual(t, w3.Header().Get("Content-Type"), "application/octet-stream")
+	AssertEqual(t, w3.Body.String(), "abc")
+}
+
+func TestRenderSse(t *testing.T) {
+	channel := make(chan RenderEvent, 3)
+	channel <- RenderEvent{Id: "1", Event: "status", Data: map[string]int{"orderId": 10001}}
+	channel <- RenderEvent{Data: "line1\nline2", Retry: 3000}
+	channel <- RenderEvent{Event: "a\nb", Data: []byte("raw")}
+	close(channel)
+
+	testCase := []struct {
+		data   interface{}
+		output string
+	}{
+		{channel, "id:1\nevent:status\ndata:{\"orderId\":10001}\n\nretry:3000\ndata:line1\ndata:line2\n\nevent:a\\nb\ndata:raw\n\n"},
+		{func(yield func(RenderEvent) bool) {
+			for i := 0; i != 2; i++ {
+				if yield(RenderEvent{Id: strconv.Itoa(i), Data: i}) == false {
+					return
+				}
+			}
+		}, "id:0\ndata:0\n\nid:1\ndata:1\n\n"},
+	}
+	renderFactory, err := NewRenderFactory(RenderConfig{})
+	AssertEqual(t, err, nil)
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+		w := httptest.NewRecorder()
+		err := renderFactory.Create(w, r).Format("sse", singleTestCase.data)
+		AssertEqual(t, err, nil, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.output, index)
+		AssertEqual(t, w.Header().Get("Content-Type"), "text/event-stream; charset=utf-8", index)
+		AssertEqual(t, w.Flushed, true, index)
+	}
+
+	//没有事件时发送心跳，客户端断开以后迭代器停止
+	renderFactory, err = NewRenderFactory(RenderConfig{SseHeartbeat: 10 * time.Millisecond})
+	AssertEqual(t, err, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	r, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+	r = r.WithContext(ctx)
+	stop := make(chan bool, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	w := httptest.NewRecorder()
+	err = renderFactory.Create(w, r).Format("sse", func(yield func(RenderEvent) bool) {
+		for yield(RenderEvent{Data: "tick"}) {
+			time.Sleep(30 * time.Millisecond)
+		}
+		stop <- true
+	})
+	AssertEqual(t, err, nil)
+	AssertEqual(t, <-stop, true)
+	AssertEqual(t, strings.HasPrefix(w.Body.String(), "data:tick\n\n"), true)
+	AssertEqual(t, strings.Contains(w.Body.String(), ": ping\n\n"), true)
+
+	//迭代器崩溃时交回给调用方
+	r2, _ := http.NewRequest("GET", "http://www.baidu.com/", nil)
+	func() {
+		defer func() {
+			AssertEqual(t, recover(), "iterator crash")
+		}()
+		renderFactory.Create(httptest.NewRecorder(), r2).Format("sse", func(yield func(RenderEvent) bool) {
+			panic("iterator crash")
+		})
+	}()
+
+	err = renderFactory.Create(httptest.NewRecorder(), r2).Format("sse", "abc")
+	AssertEqual(t, err != nil, true)
+}
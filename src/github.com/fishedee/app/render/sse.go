@@ -0,0 +1,167 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/manucorporat/sse"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type RenderEvent struct {
+	Id    string
+	Event string
+	Retry int
+	Data  interface{}
+}
+
+type SseFormatter struct {
+	heartbeat time.Duration
+}
+
+func (this *SseFormatter) Name() string {
+	return "sse"
+}
+
+func encodeSseEvent(event RenderEvent) ([]byte, error) {
+	//非字符串的数据先转为json，换行与字段的转义交给sse库
+	var data string
+	if dataString, isOk := event.Data.(string); isOk {
+		data = dataString
+	} else if dataByte, isOk := event.Data.([]byte); isOk {
+		data = string(dataByte)
+	} else {
+		dataJson, err := json.Marshal(event.Data)
+		if err != nil {
+			return nil, err
+		}
+		data = string(dataJson)
+	}
+	retry := uint(0)
+	if event.Retry > 0 {
+		retry = uint(event.Retry)
+	}
+	var buffer bytes.Buffer
+	err := sse.Encode(&buffer, sse.Event{
+		Id:    event.Id,
+		Event: event.Event,
+		Retry: retry,
+		Data:  strings.Replace(data, "\r\n", "\n", -1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+type sseSource struct {
+	channel <-chan RenderEvent
+	done    chan struct{}
+	panic   interface{}
+}
+
+func (this *sseSource) stop() {
+	if this.done != nil {
+		close(this.done)
+	}
+}
+
+func getSseSource(data interface{}) (*sseSource, error) {
+	//支持channel，或者func(yield func(RenderEvent) bool)形式的迭代器
+	if channel, isOk := data.(<-chan RenderEvent); isOk {
+		return &sseSource{channel: channel}, nil
+	} else if channel, isOk := data.(chan RenderEvent); isOk {
+		return &sseSource{channel: channel}, nil
+	} else if iterator, isOk := data.(func(yield func(RenderEvent) bool)); isOk {
+		//迭代器在另外的goroutine执行，客户端断开以后yield返回false，崩溃时交回给调用方
+		channel := make(chan RenderEvent)
+		source := &sseSource{
+			channel: channel,
+			done:    make(chan struct{}),
+		}
+		go func() {
+			defer func() {
+				source.panic = recover()
+				close(channel)
+			}()
+			iterator(func(event RenderEvent) bool {
+				select {
+				case channel <- event:
+					return true
+				case <-source.done:
+					return false
+				}
+			})
+		}()
+		return source, nil
+	}
+	return nil, errors.New("invalid data type for sse formatter")
+}
+
+func (this *SseFormatter) Format(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	source, err := getSseSource(data)
+	if err != nil {
+		return err
+	}
+	defer source.stop()
+	flusher, isOk := w.(http.Flusher)
+	if isOk == false {
+		return errors.New("sse formatter need a http.Flusher")
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", sse.ContentType+"; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var heartbeat <-chan time.Time
+	if this.heartbeat > 0 {
+		ticker := time.NewTicker(this.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	ctx := r.Context()
+	for {
+		var frame []byte
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat:
+			//注释行作为心跳，避免代理关闭空闲的连接
+			frame = []byte(": ping\n\n")
+		case event, isOk := <-source.channel:
+			if isOk == false {
+				if source.panic != nil {
+					panic(source.panic)
+				}
+				return nil
+			}
+			frame, err = encodeSseEvent(event)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = w.Write(frame)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		flusher.Flush()
+	}
+}
+
+func NewSseFormatter(heartbeat time.Duration) (*SseFormatter, error) {
+	if heartbeat < 0 {
+		return nil, errors.New("invalid sse heartbeat " + heartbeat.String())
+	}
+	return &SseFormatter{
+		heartbeat: heartbeat,
+	}, nil
+}
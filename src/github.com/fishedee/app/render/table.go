@@ -0,0 +1,56 @@
+package render
+
+import (
+	"errors"
+	. "github.com/fishedee/language"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+type RenderTable struct {
+	FileName string
+	Column   map[string]string
+	Data     interface{}
+}
+
+func getRenderTable(name string, data interface{}) ([][]string, string, error) {
+	//支持[][]string，或者RenderTable按Column把结构体数组转换为表格
+	if table, isOk := data.([][]string); isOk {
+		return table, "", nil
+	}
+	var table RenderTable
+	if dataTable, isOk := data.(RenderTable); isOk {
+		table = dataTable
+	} else if dataTable, isOk := data.(*RenderTable); isOk && dataTable != nil {
+		table = *dataTable
+	} else {
+		return nil, "", errors.New("invalid data type for " + name + " formatter")
+	}
+	if dataTable, isOk := table.Data.([][]string); isOk {
+		return dataTable, table.FileName, nil
+	}
+	if len(table.Column) == 0 {
+		return nil, "", errors.New("empty column for " + name + " formatter")
+	}
+	dataValue := reflect.ValueOf(table.Data)
+	if table.Data == nil {
+		table.Data = []interface{}{}
+	} else if dataValue.Kind() != reflect.Slice && dataValue.Kind() != reflect.Array {
+		return nil, "", errors.New("invalid table data type for " + name + " formatter")
+	}
+	return ArrayColumnTable(table.Column, table.Data), table.FileName, nil
+}
+
+func setRenderTableHeader(w http.ResponseWriter, contentType string, fileName string, ext string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if fileName == "" {
+		w.Header().Set("Content-Disposition", "attachment")
+		return
+	}
+	if strings.HasSuffix(strings.ToLower(fileName), ext) == false {
+		fileName = fileName + ext
+	}
+	w.Header().Set("Content-Disposition", getContentDisposition("attachment", fileName))
+}
@@ -0,0 +1,37 @@
+package render
+
+import (
+	. "github.com/fishedee/encoding"
+	"net/http"
+)
+
+type XlsxFormatter struct {
+}
+
+func (this *XlsxFormatter) Name() string {
+	return "xlsx"
+}
+
+func (this *XlsxFormatter) Format(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	table, fileName, err := getRenderTable("xlsx", data)
+	if err != nil {
+		return err
+	}
+	if len(table) == 0 {
+		table = [][]string{{}}
+	}
+	result, err := EncodeXlsx(table)
+	if err != nil {
+		return err
+	}
+	setRenderTableHeader(w, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", fileName, ".xlsx")
+	_, err = w.Write(result)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func NewXlsxFormatter() (*XlsxFormatter, error) {
+	return &XlsxFormatter{}, nil
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+type XmlFormatter struct {
+}
+
+type xmlValue struct {
+	value reflect.Value
+}
+
+func (this xmlValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	//encoding/xml不支持map，map按key排序输出为子元素，slice输出为同名的重复元素
+	value := this.value
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.IsValid() == false {
+		return nil
+	}
+	if value.Kind() == reflect.Map {
+		if value.Type().Key().Kind() != reflect.String {
+			return errors.New("invalid map key type for xml formatter " + value.Type().String())
+		}
+		keys := []string{}
+		for _, key := range value.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		err := e.EncodeToken(start)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			keyValue := reflect.ValueOf(key).Convert(value.Type().Key())
+			err := xmlValue{value.MapIndex(keyValue)}.MarshalXML(e, xml.StartElement{Name: xml.Name{Local: key}})
+			if err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+	if (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i != value.Len(); i++ {
+			err := xmlValue{value.Index(i)}.MarshalXML(e, start)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return e.EncodeElement(value.Interface(), start)
+}
+
+func (this *XmlFormatter) Name() string {
+	return "xml"
+}
+
+func (this *XmlFormatter) Format(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	//handler或者中间件已经指定了缓存策略时不覆盖
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Add("Cache-Control", "private, no-store, no-cache, must-revalidate, max-age=0")
+		w.Header().Set("Pragma", "no-cache")
+	}
+
+	_, err := w.Write([]byte(xml.Header))
+	if err != nil {
+		return err
+	}
+	//结构体按encoding/xml的规则输出，map与slice包在response元素里面
+	encoder := xml.NewEncoder(w)
+	dataValue := reflect.ValueOf(data)
+	for dataValue.Kind() == reflect.Ptr && dataValue.IsNil() == false {
+		dataValue = dataValue.Elem()
+	}
+	root := xml.StartElement{Name: xml.Name{Local: "response"}}
+	if dataValue.IsValid() == false || (dataValue.Kind() == reflect.Ptr && dataValue.IsNil()) {
+		err = encoder.EncodeElement("", root)
+	} else if dataValue.Kind() == reflect.Map {
+		err = xmlValue{dataValue}.MarshalXML(encoder, root)
+	} else if dataValue.Kind() == reflect.Slice || dataValue.Kind() == reflect.Array {
+		err = encoder.EncodeToken(root)
+		if err == nil {
+			err = xmlValue{dataValue}.MarshalXML(encoder, xml.StartElement{Name: xml.Name{Local: "item"}})
+		}
+		if err == nil {
+			err = encoder.EncodeToken(root.End())
+		}
+	} else {
+		err = encoder.Encode(data)
+	}
+	if err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func NewXmlFormatter() (*XmlFormatter, error) {
+	return &XmlFormatter{}, nil
+}
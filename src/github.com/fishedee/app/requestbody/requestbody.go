@@ -0,0 +1,276 @@
+package requestbody
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type RequestBody interface {
+	Limit(path string) int64
+	ServeHTTP(w http.ResponseWriter, r *http.Request, limit int64, next http.HandlerFunc)
+}
+
+type RequestBodyConfig struct {
+	MaxSize  string   `config:"maxsize"`
+	MaxRatio int      `config:"maxratio"`
+	Route    []string `config:"route"`
+}
+
+type requestBodyImplement struct {
+	maxSize   int64
+	maxRatio  int64
+	routeSize map[string]int64
+}
+
+var errRequestBodyTooLarge = errors.New("request body too large")
+
+func ParseSize(size string) (int64, error) {
+	//支持k，m，g后缀，例如10m
+	size = strings.ToLower(strings.TrimSpace(size))
+	unit := int64(1)
+	if strings.HasSuffix(size, "k") {
+		unit = 1024
+	} else if strings.HasSuffix(size, "m") {
+		unit = 1024 * 1024
+	} else if strings.HasSuffix(size, "g") {
+		unit = 1024 * 1024 * 1024
+	}
+	if unit != 1 {
+		size = size[0 : len(size)-1]
+	}
+	result, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || result < 0 {
+		return 0, errors.New("invalid size " + size)
+	}
+	return result * unit, nil
+}
+
+func NewRequestBody(config RequestBodyConfig) (RequestBody, error) {
+	maxSize := int64(10 * 1024 * 1024)
+	if config.MaxSize != "" {
+		size, err := ParseSize(config.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		maxSize = size
+	}
+	if config.MaxRatio <= 0 {
+		config.MaxRatio = 100
+	}
+	routeSize := map[string]int64{}
+	for _, route := range config.Route {
+		//路由的格式为：/upload 100m
+		fields := strings.Fields(route)
+		if len(fields) != 2 {
+			return nil, errors.New("invalid request body route " + route)
+		}
+		size, err := ParseSize(fields[1])
+		if err != nil {
+			return nil, errors.New("invalid request body route " + route)
+		}
+		routeSize[fields[0]] = size
+	}
+	return &requestBodyImplement{
+		maxSize:   maxSize,
+		maxRatio:  int64(config.MaxRatio),
+		routeSize: routeSize,
+	}, nil
+}
+
+func (this *requestBodyImplement) Limit(path string) int64 {
+	size, isExist := this.routeSize[path]
+	if isExist {
+		return size
+	}
+	return this.maxSize
+}
+
+type requestBodyState struct {
+	mutex    sync.Mutex
+	tooLarge bool
+}
+
+func (this *requestBodyState) setTooLarge() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.tooLarge = true
+}
+
+func (this *requestBodyState) isTooLarge() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.tooLarge
+}
+
+type limitReader struct {
+	reader io.Reader
+	remain int64
+	read   int64
+	state  *requestBodyState
+}
+
+func (this *limitReader) Read(p []byte) (int, error) {
+	if this.remain <= 0 {
+		//多读一个字节确认是否真的超过了限制
+		var buffer [1]byte
+		n, _ := this.reader.Read(buffer[:])
+		if n > 0 {
+			this.state.setTooLarge()
+			return 0, errRequestBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > this.remain {
+		p = p[0:this.remain]
+	}
+	n, err := this.reader.Read(p)
+	this.remain -= int64(n)
+	this.read += int64(n)
+	return n, err
+}
+
+type ratioReader struct {
+	reader   io.Reader
+	raw      *limitReader
+	maxRatio int64
+	read     int64
+	state    *requestBodyState
+}
+
+func (this *ratioReader) Read(p []byte) (int, error) {
+	n, err := this.reader.Read(p)
+	this.read += int64(n)
+	//解压后的大小远远超过压缩前的大小，认为是压缩炸弹
+	if this.read > 1024*1024 && this.read > this.raw.read*this.maxRatio {
+		this.state.setTooLarge()
+		return 0, errRequestBodyTooLarge
+	}
+	return n, err
+}
+
+type requestBodyReader struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (this *requestBodyReader) Close() error {
+	return this.closer.Close()
+}
+
+type requestBodyWriter struct {
+	writer      http.ResponseWriter
+	state       *requestBodyState
+	wroteHeader bool
+	omit        bool
+}
+
+func (this *requestBodyWriter) Header() http.Header {
+	return this.writer.Header()
+}
+
+func (this *requestBodyWriter) WriteHeader(status int) {
+	if this.wroteHeader {
+		return
+	}
+	this.wroteHeader = true
+	if this.state.isTooLarge() {
+		//handler读取body失败以后的输出换成413
+		this.omit = true
+		http.Error(this.writer, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	this.writer.WriteHeader(status)
+}
+
+func (this *requestBodyWriter) Write(data []byte) (int, error) {
+	if this.wroteHeader == false {
+		this.WriteHeader(http.StatusOK)
+	}
+	if this.omit {
+		return len(data), nil
+	}
+	return this.writer.Write(data)
+}
+
+func (this *requestBodyWriter) Flush() {
+	if this.wroteHeader == false {
+		this.WriteHeader(http.StatusOK)
+	}
+	if this.omit {
+		return
+	}
+	if flusher, isOk := this.writer.(http.Flusher); isOk {
+		flusher.Flush()
+	}
+}
+
+func newDeflateReader(reader io.Reader) (io.ReadCloser, error) {
+	//HTTP的deflate应该是zlib格式，但是不少客户端发送的是裸的deflate
+	bufReader := bufio.NewReader(reader)
+	header, err := bufReader.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(bufReader)
+	}
+	return flate.NewReader(bufReader), nil
+}
+
+func (this *requestBodyImplement) ServeHTTP(w http.ResponseWriter, r *http.Request, limit int64, next http.HandlerFunc) {
+	if r.Body == nil || r.Body == http.NoBody {
+		next(w, r)
+		return
+	}
+	if limit > 0 && r.ContentLength > limit {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	state := &requestBodyState{}
+	//没有限制时也要统计读取的原始大小，解压时用来检查压缩炸弹
+	remain := int64(math.MaxInt64)
+	if limit > 0 {
+		remain = limit
+	}
+	raw := &limitReader{reader: r.Body, remain: remain, state: state}
+	var body io.Reader = raw
+
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding != "" && encoding != "identity" {
+		var decoder io.ReadCloser
+		var err error
+		if encoding == "gzip" || encoding == "x-gzip" {
+			decoder, err = gzip.NewReader(body)
+		} else if encoding == "deflate" {
+			decoder, err = newDeflateReader(body)
+		} else {
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			if state.isTooLarge() {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			} else {
+				http.Error(w, "invalid request body encoding", http.StatusBadRequest)
+			}
+			return
+		}
+		defer decoder.Close()
+		//解压比例总是检查，解压后的大小同样受限制
+		body = &ratioReader{reader: decoder, raw: raw, maxRatio: this.maxRatio, state: state}
+		if limit > 0 {
+			body = &limitReader{reader: body, remain: limit, state: state}
+		}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+	}
+	r.Body = &requestBodyReader{Reader: body, closer: r.Body}
+	next(&requestBodyWriter{writer: w, state: state}, r)
+}
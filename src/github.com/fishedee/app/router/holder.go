@@ -0,0 +1,52 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+type RouterHolder struct {
+	router atomic.Value
+	mutex  sync.Mutex
+}
+
+func NewRouterHolder(routerFactory *RouterFactory) *RouterHolder {
+	holder := &RouterHolder{}
+	holder.router.Store(routerFactory.Create())
+	return holder
+}
+
+func (this *RouterHolder) Router() *Router {
+	return this.router.Load().(*Router)
+}
+
+func (this *RouterHolder) Update(routerFactory *RouterFactory) (err error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	//路由冲突等错误不能影响正在服务的router
+	defer func() {
+		if result := recover(); result != nil {
+			err = fmt.Errorf("%v", result)
+		}
+	}()
+
+	//新router在发布之前就复用好旧router的context池，发布以后不再修改
+	router := routerFactory.create(this.Router())
+	this.router.Store(router)
+	return nil
+}
+
+func (this *RouterHolder) MustUpdate(routerFactory *RouterFactory) {
+	err := this.Update(routerFactory)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (this *RouterHolder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	//进行中的请求继续使用旧的router，新请求使用新的router
+	this.Router().ServeHTTP(w, r)
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRouterHolder(t *testing.T) {
+	started := make(chan bool)
+	block := make(chan bool)
+	newRouterFactory := func(version string, isBlock bool) *RouterFactory {
+		routerFactory := NewRouterFactory()
+		routerFactory.GET("/user/:userId/:name", func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+			if isBlock {
+				started <- true
+				<-block
+			}
+			w.Write([]byte(version + "_" + param[0].Value + "_" + param[1].Value))
+		})
+		return routerFactory
+	}
+	serve := func(holder *RouterHolder) string {
+		r, _ := http.NewRequest("GET", "/user/1/fish", nil)
+		w := httptest.NewRecorder()
+		holder.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	//进行中的请求在旧router上完成
+	holder := NewRouterHolder(newRouterFactory("v1", true))
+	result := make(chan string)
+	go func() {
+		result <- serve(holder)
+	}()
+	<-started
+
+	err := holder.Update(newRouterFactory("v2", false))
+	AssertEqual(t, err, nil)
+	AssertEqual(t, serve(holder), "v2_1_fish")
+
+	block <- true
+	AssertEqual(t, <-result, "v1_1_fish")
+
+	//冲突的路由不会替换正在服务的router
+	conflictFactory := newRouterFactory("v3", false)
+	conflictFactory.GET("/user/:id/:name", func(w http.ResponseWriter, r *http.Request) {
+	})
+	err = holder.Update(conflictFactory)
+	AssertEqual(t, err != nil, true)
+	AssertEqual(t, serve(holder), "v2_1_fish")
+
+	//并发请求与切换
+	var wg sync.WaitGroup
+	for i := 0; i != 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j != 100; j++ {
+				data := serve(holder)
+				if data != "v2_1_fish" && data != "v4_1_fish" {
+					t.Error("invalid data " + data)
+					return
+				}
+			}
+		}()
+	}
+	holder.MustUpdate(newRouterFactory("v4", false))
+	wg.Wait()
+	AssertEqual(t, serve(holder), "v4_1_fish")
+}
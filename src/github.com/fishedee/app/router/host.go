@@ -0,0 +1,193 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type routerHost struct {
+	host    string
+	pattern *regexp.Regexp
+	param   []string
+	router  *Router
+}
+
+type routerBuildHost struct {
+	factory     *RouterFactory
+	middlewares []RouterMiddleware
+}
+
+var routerHostParam = regexp.MustCompile("\\{([^{}]+)\\}")
+
+func (this *RouterFactory) Host(host string, handler func(r *RouterFactory)) *RouterFactory {
+	if this.host != "" {
+		panic("nested host is not supported : " + host)
+	}
+	hostFactory := newRouterFactory(this.basePath)
+	hostFactory.host = strings.ToLower(host)
+	this.lastConfig = len(this.config)
+
+	this.group = append(this.group, hostFactory)
+
+	handler(hostFactory)
+	return this
+}
+
+func parseHost(host string) *routerHost {
+	//支持{tenant}.example.com的通配域名，每个参数只匹配一段
+	result := &routerHost{
+		host:  host,
+		param: []string{},
+	}
+	matches := routerHostParam.FindAllStringSubmatchIndex(host, -1)
+	if len(matches) == 0 {
+		return result
+	}
+	expr := "^"
+	begin := 0
+	for _, match := range matches {
+		expr += regexp.QuoteMeta(host[begin:match[0]]) + "([^.]+)"
+		result.param = append(result.param, host[match[2]:match[3]])
+		begin = match[1]
+	}
+	expr += regexp.QuoteMeta(host[begin:]) + "$"
+	result.pattern = regexp.MustCompile(expr)
+	return result
+}
+
+func (this *routerHost) match(host string) (RouterParam, bool) {
+	if this.pattern == nil {
+		return nil, this.host == host
+	}
+	matches := this.pattern.FindStringSubmatch(host)
+	if matches == nil {
+		return nil, false
+	}
+	param := make(RouterParam, 0, len(this.param))
+	for i, name := range this.param {
+		param = append(param, RouterSingleParam{
+			Key:   name,
+			Value: matches[i+1],
+		})
+	}
+	return param, true
+}
+
+func combineHostParam(hostParam RouterParam, param RouterParam) RouterParam {
+	//域名参数排在路径参数前面
+	if len(hostParam) == 0 {
+		return param
+	}
+	return append(hostParam[0:len(hostParam):len(hostParam)], param...)
+}
+
+func getRequestHost(r *http.Request) string {
+	host := r.Host
+	//去掉端口，兼容ipv6地址
+	portIndex := strings.LastIndexByte(host, ':')
+	if portIndex != -1 && strings.IndexByte(host[portIndex:], ']') == -1 {
+		host = host[0:portIndex]
+	}
+	return strings.ToLower(host)
+}
+
+func (this *Router) findHost(r *http.Request) (*Router, RouterParam) {
+	if len(this.host) == 0 {
+		return this, nil
+	}
+	host := getRequestHost(r)
+	for _, singleHost := range this.host {
+		param, isMatch := singleHost.match(host)
+		if isMatch {
+			return singleHost.router, param
+		}
+	}
+	return this, nil
+}
+
+func (this *Router) serveFallback(w http.ResponseWriter, r *http.Request, methodInt int, pathInfo routerPathInfo, searchUrl string, isExact bool, param RouterParam) {
+	//域名下找不到的路由，先回退到不限域名的路由
+	fallback := this.fallback
+	fallbackPathInfo, fallbackSearchUrl, fallbackIsExact := fallback.findPathInfo(r.URL.Path)
+	fallbackAllow := fallback.findAllowMethod(fallbackPathInfo, fallbackSearchUrl, fallbackIsExact)
+	if fallbackAllow[methodInt] ||
+		(methodInt == RouterMethod.HEAD && fallbackAllow[RouterMethod.GET]) {
+		fallback.serveHTTP(w, r, nil)
+		return
+	}
+
+	//两边的方法合并成Allow，域名下没有定义的兜底路由使用不限域名的
+	isAllow := this.findAllowMethod(pathInfo, searchUrl, isExact)
+	for i := range isAllow {
+		isAllow[i] = isAllow[i] || fallbackAllow[i]
+	}
+	handler := pathInfo[methodInt]
+	fallbackHandler := &fallbackPathInfo[methodInt]
+	if handler.optionsPrefixHandler == nil {
+		handler.optionsPrefixHandler = fallbackHandler.optionsPrefixHandler
+	}
+	if handler.methodNotAllowedPrefixHandler == nil {
+		handler.methodNotAllowedPrefixHandler = fallbackHandler.methodNotAllowedPrefixHandler
+	}
+	if handler.notFoundPrefixHandler == nil {
+		handler.notFoundPrefixHandler = fallbackHandler.notFoundPrefixHandler
+	}
+	this.serveNotFound(w, r, &handler, isAllow, methodInt, param)
+}
+
+func createHostRouter(router *Router, buildInfo *routerBuildInfo, oldRouter *Router) []routerHost {
+	hostMap := map[string]*routerHost{}
+	hostList := []*routerHost{}
+	hostBuildInfo := map[string]*routerBuildInfo{}
+	for _, singleHost := range buildInfo.host {
+		host := singleHost.factory.host
+		singleBuildInfo, isExist := hostBuildInfo[host]
+		if isExist == false {
+			singleBuildInfo = newRouterBuildInfo()
+			singleBuildInfo.urlBuilder = buildInfo.urlBuilder
+			//根节点需要有所有的方法
+			rootPathInfo := routerFactoryPathInfo{}
+			for i := RouterMethod.HEAD; i <= RouterMethod.PATCH; i++ {
+				rootPathInfo[i] = &routerFactoryHandler{
+					urlPrefixHandler: map[int][]*routerFactoryUrlPrefixHandler{},
+				}
+			}
+			singleBuildInfo.tree[""] = rootPathInfo
+			hostBuildInfo[host] = singleBuildInfo
+			hostMap[host] = parseHost(host)
+			hostList = append(hostList, hostMap[host])
+		}
+		buildRouterMapRecursive(singleBuildInfo, singleHost.factory, singleHost.middlewares)
+		if len(singleBuildInfo.host) != 0 {
+			panic("nested host is not supported : " + singleBuildInfo.host[0].factory.host)
+		}
+	}
+
+	//固定域名优先于通配域名
+	result := []routerHost{}
+	for _, isWildcard := range []bool{false, true} {
+		for _, singleHost := range hostList {
+			if (singleHost.pattern != nil) != isWildcard {
+				continue
+			}
+			singleBuildInfo := hostBuildInfo[singleHost.host]
+			for i := range singleBuildInfo.routes {
+				singleBuildInfo.routes[i].Host = singleHost.host
+			}
+			var oldHostRouter *Router
+			if oldRouter != nil {
+				for _, oldHost := range oldRouter.host {
+					if oldHost.host == singleHost.host {
+						oldHostRouter = oldHost.router
+					}
+				}
+			}
+			singleHost.router = createRouter(singleBuildInfo, oldHostRouter)
+			singleHost.router.fallback = router
+			buildInfo.onCreate = append(buildInfo.onCreate, singleBuildInfo.onCreate...)
+			result = append(result, *singleHost)
+		}
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"fmt"
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterHost(t *testing.T) {
+	newHandler := func(data string) func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+		return func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+			w.Write([]byte(data + fmt.Sprintf("%v", param)))
+		}
+	}
+	routerFactory := NewRouterFactory()
+	routerFactory.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		w.Write([]byte("404"))
+	})
+	routerFactory.GET("/", newHandler("main"))
+	routerFactory.GET("/health", newHandler("health"))
+	routerFactory.POST("/user/:userId", newHandler("main_user"))
+	routerFactory.Host("admin.example.com", func(routerFactory2 *RouterFactory) {
+		routerFactory2.GET("/", newHandler("admin"))
+		routerFactory2.GET("/user/:userId", newHandler("admin_user")).Name("admin.user")
+	})
+	routerFactory.Host("{tenant}.example.com", func(routerFactory2 *RouterFactory) {
+		routerFactory2.GET("/", newHandler("tenant"))
+		routerFactory2.Group("/order", func(routerFactory3 *RouterFactory) {
+			routerFactory3.POST("/:orderId", newHandler("tenant_order"))
+		})
+	})
+	routerFactory.Host("{tenant}.{region}.example.cn", func(routerFactory2 *RouterFactory) {
+		routerFactory2.GET("/", newHandler("region"))
+	})
+	router := routerFactory.Create()
+
+	testCase := []struct {
+		method string
+		host   string
+		url    string
+		status int
+		data   string
+	}{
+		{"GET", "www.other.com", "/", 200, "main[]"},
+		{"GET", "admin.example.com", "/", 200, "admin[]"},
+		{"GET", "ADMIN.example.com:8080", "/", 200, "admin[]"},
+		{"GET", "admin.example.com", "/user/10001", 200, "admin_user[{userId 10001}]"},
+		{"GET", "admin.example.com", "/health", 200, "health[]"},
+		{"GET", "admin.example.com", "/mc", 404, "404"},
+		{"POST", "admin.example.com", "/user/10001", 200, "main_user[{userId 10001}]"},
+		{"PUT", "admin.example.com", "/user/10001", 405, "405 method not allowed By Fish"},
+		{"OPTIONS", "admin.example.com", "/user/10001", 200, ""},
+		{"GET", "fish.example.com", "/", 200, "tenant[{tenant fish}]"},
+		{"POST", "fish.example.com", "/order/123", 200, "tenant_order[{tenant fish} {orderId 123}]"},
+		{"GET", "fish.example.com", "/order/123", 405, "405 method not allowed By Fish"},
+		{"GET", "a.b.example.com", "/", 200, "main[]"},
+		{"GET", "fish.gz.example.cn", "/", 200, "region[{tenant fish} {region gz}]"},
+		{"GET", "www.other.com", "/user/10001", 405, "405 method not allowed By Fish"},
+		{"GET", "www.other.com", "/order/123", 404, "404"},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest(singleTestCase.method, "http://"+singleTestCase.host+singleTestCase.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.data, index)
+	}
+	AssertEqual(t, router.MustURL("admin.user", "userId", 1), "/user/1")
+	AssertEqual(t, len(router.Routes()), 8)
+
+	r, _ := http.NewRequest("PUT", "http://admin.example.com/user/10001", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	AssertEqual(t, w.Header().Get("Allow"), "HEAD, OPTIONS, GET, POST")
+}
+
+func TestRouterNestedHost(t *testing.T) {
+	defer func() {
+		err := recover()
+		AssertEqual(t, err, "nested host is not supported : b.example.com")
+	}()
+	routerFactory := NewRouterFactory()
+	routerFactory.Host("a.example.com", func(routerFactory2 *RouterFactory) {
+		routerFactory2.Group("/a", func(routerFactory3 *RouterFactory) {
+			routerFactory3.Host("b.example.com", func(routerFactory4 *RouterFactory) {
+			})
+		})
+	})
+	routerFactory.Create()
+}
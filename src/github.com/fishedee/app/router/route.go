@@ -0,0 +1,214 @@
+package router
+
+import (
+	"fmt"
+	. "github.com/fishedee/language"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+)
+
+type RouterRoute struct {
+	Host        string
+	Method      string
+	Path        string
+	Name        string
+	Handler     string
+	Middleware  []string
+	Data        map[string]interface{}
+	HandlerType reflect.Type
+}
+
+type routerRouteInfo struct {
+	RouterRoute
+	method   int
+	priority int
+}
+
+type routerDebugHandler struct {
+	routes []RouterRoute
+}
+
+var routerFuncSuffix = regexp.MustCompile("(\\.func[0-9]+)+$")
+
+func getFuncName(handler interface{}) string {
+	handlerValue := reflect.ValueOf(handler)
+	if handlerValue.Kind() != reflect.Func {
+		return reflect.TypeOf(handler).String()
+	}
+	name := runtime.FuncForPC(handlerValue.Pointer()).Name()
+	//去掉闭包与方法值的后缀
+	name = strings.TrimSuffix(name, "-fm")
+	name = routerFuncSuffix.ReplaceAllString(name, "")
+	return name
+}
+
+func getHandlerName(config routerConfig) string {
+	if config.handlerName != "" {
+		return config.handlerName
+	}
+	if middlewareContext, isOk := config.handler.(RouterMiddlewareContext); isOk {
+		if name, isOk := middlewareContext.Data["name"].(string); isOk {
+			return name
+		}
+		return getFuncName(middlewareContext.Handler)
+	}
+	return getFuncName(config.handler)
+}
+
+func getHandlerInfo(config routerConfig) (map[string]interface{}, reflect.Type) {
+	//文档生成等工具需要拿到中间件之前的原始handler
+	if middlewareContext, isOk := config.handler.(RouterMiddlewareContext); isOk {
+		return middlewareContext.Data, reflect.TypeOf(middlewareContext.Handler)
+	}
+	return map[string]interface{}{}, reflect.TypeOf(config.handler)
+}
+
+func getMiddlewareName(middlewares []RouterMiddleware) []string {
+	result := []string{}
+	for _, middleware := range middlewares {
+		result = append(result, getFuncName(middleware))
+	}
+	return result
+}
+
+func addRouteInfo(routes *[]routerRouteInfo, middlewares []RouterMiddleware, config routerConfig, path string) {
+	//只记录业务路由与静态目录，NotFound与MethodNotAllowed属于兜底路由
+	if config.priority != 1 && config.priority != 3 {
+		return
+	}
+	data, handlerType := getHandlerInfo(config)
+	*routes = append(*routes, routerRouteInfo{
+		RouterRoute: RouterRoute{
+			Method:      RouterMethod.Entrys()[config.method],
+			Path:        path,
+			Name:        config.name,
+			Handler:     getHandlerName(config),
+			Middleware:  getMiddlewareName(middlewares),
+			Data:        data,
+			HandlerType: handlerType,
+		},
+		method:   config.method,
+		priority: config.priority,
+	})
+}
+
+func getRoutePattern(path string) ([]string, int) {
+	//参数名不影响匹配，只保留参数类型与约束
+	pattern := []string{}
+	literal := -1
+	for index, singlePath := range Explode(path, "/") {
+		if singlePath[0] != ':' && singlePath[0] != '*' {
+			pattern = append(pattern, singlePath)
+			continue
+		}
+		if literal == -1 {
+			literal = index
+		}
+		_, constraint := parseUrlParam(singlePath[1:])
+		if constraint != nil {
+			pattern = append(pattern, singlePath[0:1]+constraint.String())
+		} else {
+			pattern = append(pattern, singlePath[0:1])
+		}
+	}
+	if literal == -1 {
+		literal = len(pattern)
+	}
+	return pattern, literal
+}
+
+func isPatternPrefix(pattern []string, prefix []string) bool {
+	if len(prefix) > len(pattern) {
+		return false
+	}
+	for i := 0; i != len(prefix); i++ {
+		if pattern[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func checkRouteConflict(routes []routerRouteInfo) []string {
+	conflict := []string{}
+
+	//同一方法同一路径注册了两次
+	exist := map[string]routerRouteInfo{}
+	for _, route := range routes {
+		pattern, _ := getRoutePattern(route.Path)
+		key := fmt.Sprintf("%v %v /%v", route.priority, route.Method, Implode(pattern, "/"))
+		if existRoute, isExist := exist[key]; isExist {
+			conflict = append(conflict, fmt.Sprintf("%v %v is registered twice with %v", route.Method, route.Path, existRoute.Path))
+			continue
+		}
+		exist[key] = route
+	}
+
+	//静态目录被参数路由遮蔽
+	for _, staticRoute := range routes {
+		if staticRoute.priority != 3 {
+			continue
+		}
+		staticPattern, _ := getRoutePattern(staticRoute.Path)
+		for _, paramRoute := range routes {
+			if paramRoute.priority != 1 || paramRoute.method != staticRoute.method {
+				continue
+			}
+			paramPattern, literal := getRoutePattern(paramRoute.Path)
+			if literal == len(paramPattern) {
+				continue
+			}
+			//祖先节点的通配路由排在静态目录后面，只有同一节点的参数路由才会遮蔽静态目录
+			literalPattern := paramPattern[0:literal]
+			if len(literalPattern) == len(staticPattern) && isPatternPrefix(staticPattern, literalPattern) {
+				conflict = append(conflict, fmt.Sprintf("%v %v static route is shadowed by %v", staticRoute.Method, staticRoute.Path, paramRoute.Path))
+			}
+		}
+	}
+	return conflict
+}
+
+func (this *Router) Routes() []RouterRoute {
+	result := make([]RouterRoute, len(this.routes), len(this.routes))
+	copy(result, this.routes)
+	for _, singleHost := range this.host {
+		result = append(result, singleHost.router.Routes()...)
+	}
+	return result
+}
+
+func (this *RouterFactory) OnCreate(handler func(router *Router)) *RouterFactory {
+	//Create完成以后回调，用来拿到完整的路由表
+	this.onCreate = append(this.onCreate, handler)
+	return this
+}
+
+func (this *RouterFactory) Debug(path string) *RouterFactory {
+	//类似pprof的调试页面，在Create的时候填充路由表
+	debugHandler := &routerDebugHandler{}
+	this.lastConfig = len(this.config)
+	this.OnCreate(func(router *Router) {
+		debugHandler.routes = router.Routes()
+	})
+	this.addRoute(RouterMethod.GET, 1, path, RouterMiddlewareContext{
+		Data: map[string]interface{}{
+			"name": "Debug",
+		},
+		Handler: debugHandler.ServeHTTP,
+	})
+	return this
+}
+
+func (this *routerDebugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writer := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "HOST\tMETHOD\tPATH\tNAME\tHANDLER\tMIDDLEWARE\n")
+	for _, route := range this.routes {
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\n", route.Host, route.Method, route.Path, route.Name, route.Handler, Implode(route.Middleware, " > "))
+	}
+	writer.Flush()
+}
@@ -0,0 +1,116 @@
+package router
+
+import (
+	. "github.com/fishedee/assert"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func doRouteNothing(w http.ResponseWriter, r *http.Request) {
+}
+
+func TestRouterConflict(t *testing.T) {
+	testCase := []struct {
+		handler    func(routerFactory *RouterFactory)
+		isConflict bool
+	}{
+		{func(routerFactory *RouterFactory) {
+			routerFactory.GET("/a", doRouteNothing)
+			routerFactory.POST("/a", doRouteNothing)
+			routerFactory.GET("/a/:id", doRouteNothing)
+			routerFactory.GET("/a/:id(int)", doRouteNothing)
+			routerFactory.GET("/a/b", doRouteNothing)
+		}, false},
+		{func(routerFactory *RouterFactory) {
+			routerFactory.GET("/a", doRouteNothing)
+			routerFactory.Group("/", func(routerFactory2 *RouterFactory) {
+				routerFactory2.GET("/a", doRouteNothing)
+			})
+		}, true},
+		{func(routerFactory *RouterFactory) {
+			routerFactory.Any("/a", doRouteNothing)
+			routerFactory.POST("/a", doRouteNothing)
+		}, true},
+		{func(routerFactory *RouterFactory) {
+			routerFactory.GET("/a/:userId", doRouteNothing)
+			routerFactory.GET("/a/:id", doRouteNothing)
+		}, true},
+		{func(routerFactory *RouterFactory) {
+			routerFactory.Static("/static", "./testdata")
+			routerFactory.GET("/static/a/:id", doRouteNothing)
+		}, false},
+		{func(routerFactory *RouterFactory) {
+			routerFactory.Static("/static", "./testdata")
+			routerFactory.GET("/static/:file", doRouteNothing)
+		}, true},
+		{func(routerFactory *RouterFactory) {
+			routerFactory.Static("/static", "./testdata")
+			routerFactory.GET("/*path", doRouteNothing)
+		}, false},
+		{func(routerFactory *RouterFactory) {
+			routerFactory.Static("/static", "./testdata")
+			routerFactory.GET("/static/*path", doRouteNothing)
+		}, true},
+	}
+	for index, singleTestCase := range testCase {
+		routerFactory := NewRouterFactory()
+		singleTestCase.handler(routerFactory)
+		isConflict := false
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					isConflict = true
+				}
+			}()
+			routerFactory.Create()
+		}()
+		AssertEqual(t, isConflict, singleTestCase.isConflict, index)
+	}
+}
+
+func TestRouterRoutes(t *testing.T) {
+	middleware := func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		return prev
+	}
+	routerFactory := NewRouterFactory()
+	routerFactory.GET("/a", doRouteNothing).Name("a")
+	routerFactory.Group("/b", func(routerFactory2 *RouterFactory) {
+		routerFactory2.Use(middleware)
+		routerFactory2.POST("/:id", doRouteNothing)
+		routerFactory2.Static("/static", "./testdata")
+	})
+	routerFactory.Debug("/debug/routes")
+	router := routerFactory.Create()
+
+	routes := router.Routes()
+	AssertEqual(t, len(routes), 5)
+	AssertEqual(t, routes[0].Method, "GET")
+	AssertEqual(t, routes[0].Path, "/a")
+	AssertEqual(t, routes[0].Name, "a")
+	AssertEqual(t, routes[0].Handler, "github.com/fishedee/app/router.doRouteNothing")
+	AssertEqual(t, routes[0].Middleware, []string{})
+	AssertEqual(t, routes[0].HandlerType, reflect.TypeOf(doRouteNothing))
+	AssertEqual(t, routes[1].Method, "GET")
+	AssertEqual(t, routes[1].Path, "/debug/routes")
+	AssertEqual(t, routes[1].Handler, "Debug")
+	AssertEqual(t, routes[1].Data["name"], "Debug")
+	AssertEqual(t, routes[2].Method, "POST")
+	AssertEqual(t, routes[2].Path, "/b/:id")
+	AssertEqual(t, routes[2].Middleware, []string{"github.com/fishedee/app/router.TestRouterRoutes"})
+	AssertEqual(t, routes[3].Method, "HEAD")
+	AssertEqual(t, routes[3].Path, "/b/static")
+	AssertEqual(t, routes[3].Handler, "Static(./testdata)")
+	AssertEqual(t, routes[4].Method, "GET")
+	AssertEqual(t, routes[4].Path, "/b/static")
+
+	r, _ := http.NewRequest("GET", "/debug/routes", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	AssertEqual(t, len(lines), 6)
+	AssertEqual(t, strings.Fields(lines[0]), []string{"HOST", "METHOD", "PATH", "NAME", "HANDLER", "MIDDLEWARE"})
+	AssertEqual(t, strings.Fields(lines[3]), []string{"POST", "/b/:id", "github.com/fishedee/app/router.doRouteNothing", "github.com/fishedee/app/router.TestRouterRoutes"})
+}
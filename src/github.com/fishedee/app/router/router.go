@@ -6,13 +6,19 @@ import (
 	. "github.com/fishedee/language"
 	"net/http"
 	"reflect"
+	"regexp"
 	"strings"
 	"sync"
 )
 
 type Router struct {
-	trie *TrieArray
-	pool sync.Pool
+	trie       *TrieArray
+	pool       *sync.Pool
+	maxSegment int
+	url        map[string][]routerUrlSegment
+	routes     []RouterRoute
+	host       []routerHost
+	fallback   *Router
 }
 
 type RouterSingleParam struct {
@@ -29,30 +35,49 @@ type routerContext struct {
 type routerHandlerFunc func(w http.ResponseWriter, r *http.Request, param RouterParam) int
 
 type routerUrlPrefixHandlerParam struct {
-	index int
-	name  string
+	index      int
+	name       string
+	constraint *regexp.Regexp
 }
 type routerUrlPrefixHandler struct {
 	segment int
+	depth   int
 	param   []routerUrlPrefixHandlerParam
 	handler routerHandlerFunc
 }
 
 type routerHandler struct {
-	prefix                []string
-	prefixLength          int
-	urlExactHandler       routerHandlerFunc
-	urlPrefixHandler      []routerUrlPrefixHandler
-	staticPrefixHandler   routerHandlerFunc
-	notFoundPrefixHandler routerHandlerFunc
+	prefix                        []string
+	prefixLength                  int
+	urlExactHandler               routerHandlerFunc
+	urlPrefixHandler              []routerUrlPrefixHandler
+	urlWildcardHandler            []routerUrlPrefixHandler
+	staticPrefixHandler           routerHandlerFunc
+	staticDepth                   int
+	notFoundPrefixHandler         routerHandlerFunc
+	methodNotAllowedPrefixHandler routerHandlerFunc
+	optionsPrefixHandler          routerHandlerFunc
 }
 
 type routerPathInfo []routerHandler
 
-func newRouter(trieTree *TrieTree, maxSegment int) *Router {
+func newRouter(trieTree *TrieTree, buildInfo *routerBuildInfo, oldRouter *Router) *Router {
+	maxSegment := buildInfo.maxSegment
 	router := &Router{}
 	router.trie = router.build(trieTree)
-	router.pool = sync.Pool{
+	router.url = router.buildUrl(buildInfo.urlName)
+	router.routes = []RouterRoute{}
+	for _, route := range buildInfo.routes {
+		router.routes = append(router.routes, route.RouterRoute)
+	}
+	if oldRouter != nil && oldRouter.maxSegment >= maxSegment {
+		//旧池中的context足够容纳新路由的参数，直接复用
+		router.maxSegment = oldRouter.maxSegment
+		router.pool = oldRouter.pool
+		return router
+	}
+	router.maxSegment = maxSegment
+	router.pool = &sync.Pool{
 		New: func() interface{} {
 			return &routerContext{
 				param: make([]RouterSingleParam, maxSegment, maxSegment),
@@ -67,28 +92,43 @@ func (this *Router) combineParent(current routerFactoryPathInfo, parent routerFa
 		currentMethodInfo, isExist := current[method]
 		if isExist == false {
 			currentMethodInfo = &routerFactoryHandler{
-				urlPrefixHandler: map[int]*routerFactoryUrlPrefixHandler{},
+				urlPrefixHandler: map[int][]*routerFactoryUrlPrefixHandler{},
 			}
 			current[method] = currentMethodInfo
 		}
-		//合并urlPrefixHandler
+		//合并urlPrefixHandler，父节点的候选路由排在后面
 		for seg, parentUrlPrefixHandler := range parentMethodInfo.urlPrefixHandler {
-			_, isExist := currentMethodInfo.urlPrefixHandler[seg]
-			if isExist == false {
-				currentMethodInfo.urlPrefixHandler[seg] = parentUrlPrefixHandler
-			}
+			currentMethodInfo.urlPrefixHandler[seg] = this.combineUrlPrefixHandler(currentMethodInfo.urlPrefixHandler[seg], parentUrlPrefixHandler)
 		}
+		//合并urlWildcardHandler，父节点的候选路由排在后面
+		currentMethodInfo.urlWildcardHandler = this.combineUrlPrefixHandler(currentMethodInfo.urlWildcardHandler, parentMethodInfo.urlWildcardHandler)
 		//合并staticPrefixHandler
 		if currentMethodInfo.staticPrefixHandler == nil {
 			currentMethodInfo.staticPrefixHandler = parentMethodInfo.staticPrefixHandler
+			currentMethodInfo.staticDepth = parentMethodInfo.staticDepth
 		}
 		//合并notFoundPrefixHandler
 		if currentMethodInfo.notFoundPrefixHandler == nil {
 			currentMethodInfo.notFoundPrefixHandler = parentMethodInfo.notFoundPrefixHandler
 		}
+		//合并methodNotAllowedPrefixHandler
+		if currentMethodInfo.methodNotAllowedPrefixHandler == nil {
+			currentMethodInfo.methodNotAllowedPrefixHandler = parentMethodInfo.methodNotAllowedPrefixHandler
+		}
+		//合并optionsPrefixHandler
+		if currentMethodInfo.optionsPrefixHandler == nil {
+			currentMethodInfo.optionsPrefixHandler = parentMethodInfo.optionsPrefixHandler
+		}
 	}
 }
 
+func (this *Router) combineUrlPrefixHandler(current []*routerFactoryUrlPrefixHandler, parent []*routerFactoryUrlPrefixHandler) []*routerFactoryUrlPrefixHandler {
+	result := make([]*routerFactoryUrlPrefixHandler, 0, len(current)+len(parent))
+	result = append(result, current...)
+	result = append(result, parent...)
+	return result
+}
+
 type routerResponseWriter struct {
 	writer http.ResponseWriter
 	omit   bool
@@ -123,6 +163,12 @@ func (this *routerResponseWriter) WriteHeader(status int) {
 	this.writer.WriteHeader(status)
 }
 
+func (this *routerResponseWriter) Flush() {
+	if flusher, isOk := this.writer.(http.Flusher); isOk {
+		flusher.Flush()
+	}
+}
+
 func (this *routerResponseWriter) GetStatus() int {
 	return this.status
 }
@@ -143,41 +189,65 @@ func (this *Router) catchNotFound(in interface{}, isCatch bool) routerHandlerFun
 	}
 }
 
+func (this *Router) changeUrlPrefixHandler(origin []*routerFactoryUrlPrefixHandler) []routerUrlPrefixHandler {
+	result := []routerUrlPrefixHandler{}
+	for _, singleUrlPrefixHandler := range origin {
+		param := []routerUrlPrefixHandlerParam{}
+		for key, value := range singleUrlPrefixHandler.param {
+			param = append(param, routerUrlPrefixHandlerParam{
+				index:      key,
+				name:       value,
+				constraint: singleUrlPrefixHandler.constraint[key],
+			})
+		}
+		param = QuerySort(param, "index asc").([]routerUrlPrefixHandlerParam)
+		result = append(result, routerUrlPrefixHandler{
+			segment: singleUrlPrefixHandler.segment,
+			depth:   singleUrlPrefixHandler.depth,
+			param:   param,
+			handler: this.catchNotFound(singleUrlPrefixHandler.handler, false),
+		})
+	}
+	return result
+}
+
 func (this *Router) changeMethod(origin *routerFactoryHandler) routerHandler {
 	result := routerHandler{
-		urlExactHandler:       nil,
-		urlPrefixHandler:      []routerUrlPrefixHandler{},
-		staticPrefixHandler:   nil,
-		notFoundPrefixHandler: nil,
+		urlExactHandler:               nil,
+		urlPrefixHandler:              []routerUrlPrefixHandler{},
+		urlWildcardHandler:            []routerUrlPrefixHandler{},
+		staticPrefixHandler:           nil,
+		staticDepth:                   -1,
+		notFoundPrefixHandler:         nil,
+		methodNotAllowedPrefixHandler: nil,
+		optionsPrefixHandler:          nil,
 	}
 	if origin.urlExactHandler != nil {
 		result.urlExactHandler = this.catchNotFound(origin.urlExactHandler, false)
 	}
 	if origin.urlPrefixHandler != nil {
 		resultUrlPrefixHandler := []routerUrlPrefixHandler{}
-		for seg, singleUrlPrefixHandler := range origin.urlPrefixHandler {
-			param := []routerUrlPrefixHandlerParam{}
-			for key, value := range singleUrlPrefixHandler.param {
-				param = append(param, routerUrlPrefixHandlerParam{
-					index: key,
-					name:  value,
-				})
-			}
-			param = QuerySort(param, "index asc").([]routerUrlPrefixHandlerParam)
-			resultUrlPrefixHandler = append(resultUrlPrefixHandler, routerUrlPrefixHandler{
-				segment: seg,
-				param:   param,
-				handler: this.catchNotFound(singleUrlPrefixHandler.handler, false),
-			})
+		for _, multiUrlPrefixHandler := range origin.urlPrefixHandler {
+			resultUrlPrefixHandler = append(resultUrlPrefixHandler, this.changeUrlPrefixHandler(multiUrlPrefixHandler)...)
 		}
 		result.urlPrefixHandler = QuerySort(resultUrlPrefixHandler, "segment asc").([]routerUrlPrefixHandler)
 	}
+	if origin.urlWildcardHandler != nil {
+		result.urlWildcardHandler = this.changeUrlPrefixHandler(origin.urlWildcardHandler)
+	}
 	if origin.staticPrefixHandler != nil {
 		result.staticPrefixHandler = this.catchNotFound(origin.staticPrefixHandler, true)
+		result.staticDepth = origin.staticDepth
 	}
 	if origin.notFoundPrefixHandler != nil {
 		result.notFoundPrefixHandler = this.catchNotFound(origin.notFoundPrefixHandler, false)
 	}
+	if origin.methodNotAllowedPrefixHandler != nil {
+		result.methodNotAllowedPrefixHandler = this.catchNotFound(origin.methodNotAllowedPrefixHandler, false)
+	}
+	if origin.optionsPrefixHandler != nil {
+		result.optionsPrefixHandler = this.catchNotFound(origin.optionsPrefixHandler, false)
+	}
 	return result
 }
 
@@ -262,7 +332,45 @@ func (this *Router) parseParam(prefix []string, suffix string, maxParam int) (Ro
 	return param[0:k], context, true
 }
 
-func (this *Router) findHandler(url string, method int) (routerHandlerFunc, RouterParam, routerHandlerFunc, *routerContext) {
+func (this *Router) parseWildcardParam(url string, segment int) (RouterParam, *routerContext, bool) {
+	context := this.pool.Get().(*routerContext)
+	param := context.param
+	begin := 0
+	for k := 0; k <= segment; k++ {
+		for begin < len(url) && url[begin] == '/' {
+			begin++
+		}
+		if k == segment {
+			//剩余的url全部归入通配参数
+			param[k].Value = url[begin:]
+			break
+		}
+		if begin >= len(url) {
+			this.pool.Put(context)
+			return nil, nil, false
+		}
+		end := begin
+		for end < len(url) && url[end] != '/' {
+			end++
+		}
+		param[k].Value = url[begin:end]
+		begin = end
+	}
+	return param[0 : segment+1], context, true
+}
+
+func (this *Router) matchParam(param RouterParam, urlParam []routerUrlPrefixHandlerParam) bool {
+	for _, singleParam := range urlParam {
+		param[singleParam.index].Key = singleParam.name
+		if singleParam.constraint != nil &&
+			singleParam.constraint.MatchString(param[singleParam.index].Value) == false {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *Router) findPathInfo(url string) (routerPathInfo, string, bool) {
 	searchUrl := this.normalUrl(url, 1)
 	var isExact bool
 	var handlerValue interface{}
@@ -273,9 +381,12 @@ func (this *Router) findHandler(url string, method int) (routerHandlerFunc, Rout
 		handlerKey, handlerValue = this.trie.LongestPrefixMatch(searchUrl)
 		isExact = len(handlerKey) == len(searchUrl)
 	}
-	handler := handlerValue.(routerPathInfo)[method]
+	return handlerValue.(routerPathInfo), searchUrl, isExact
+}
+
+func (this *Router) findHandler(handler *routerHandler, searchUrl string, isExact bool) (routerHandlerFunc, RouterParam, *routerContext) {
 	if handler.urlExactHandler != nil && isExact {
-		return handler.urlExactHandler, nil, handler.notFoundPrefixHandler, nil
+		return handler.urlExactHandler, nil, nil
 	}
 	if len(handler.urlPrefixHandler) != 0 {
 		maxParam := handler.urlPrefixHandler[len(handler.urlPrefixHandler)-1].segment
@@ -283,24 +394,110 @@ func (this *Router) findHandler(url string, method int) (routerHandlerFunc, Rout
 		param, context, isValid := this.parseParam(handler.prefix, suffixUrl, maxParam)
 		if isValid {
 			for _, urlPrefixHandler := range handler.urlPrefixHandler {
-				if urlPrefixHandler.segment == len(param) {
-					for _, singleParam := range urlPrefixHandler.param {
-						param[singleParam.index].Key = singleParam.name
-					}
-					begin := urlPrefixHandler.param[0].index
-					end := len(param)
-					return urlPrefixHandler.handler, param[begin:end], handler.notFoundPrefixHandler, context
+				if urlPrefixHandler.segment != len(param) {
+					continue
+				}
+				if this.matchParam(param, urlPrefixHandler.param) == false {
+					continue
 				}
+				begin := urlPrefixHandler.param[0].index
+				end := len(param)
+				return urlPrefixHandler.handler, param[begin:end], context
 			}
+			this.pool.Put(context)
 		}
 	}
+	return this.findWildcardHandler(handler, searchUrl, false)
+}
+
+func (this *Router) findWildcardHandler(handler *routerHandler, searchUrl string, isInherit bool) (routerHandlerFunc, RouterParam, *routerContext) {
+	//比静态目录更浅的祖先通配路由，要等静态目录找不到文件以后才尝试
+	for _, urlWildcardHandler := range handler.urlWildcardHandler {
+		if (urlWildcardHandler.depth < handler.staticDepth) != isInherit {
+			continue
+		}
+		param, context, isValid := this.parseWildcardParam(searchUrl, urlWildcardHandler.segment)
+		if isValid == false {
+			continue
+		}
+		if this.matchParam(param, urlWildcardHandler.param) == false {
+			this.pool.Put(context)
+			continue
+		}
+		begin := urlWildcardHandler.param[0].index
+		end := len(param)
+		return urlWildcardHandler.handler, param[begin:end], context
+	}
+	return nil, nil, nil
+}
+
+func (this *Router) hasHandler(handler *routerHandler, searchUrl string, isExact bool) bool {
 	if handler.staticPrefixHandler != nil {
-		return handler.staticPrefixHandler, nil, handler.notFoundPrefixHandler, nil
+		return true
+	}
+	routeHandler, _, context := this.findHandler(handler, searchUrl, isExact)
+	if context != nil {
+		this.pool.Put(context)
+	}
+	return routeHandler != nil
+}
+
+func (this *Router) findAllowMethod(pathInfo routerPathInfo, searchUrl string, isExact bool) []bool {
+	isAllow := make([]bool, len(pathInfo))
+	for i := RouterMethod.HEAD; i <= RouterMethod.PATCH; i++ {
+		isAllow[i] = this.hasHandler(&pathInfo[i], searchUrl, isExact)
+	}
+	return isAllow
+}
+
+func (this *Router) formatAllowMethod(isAllow []bool, methodInt int) string {
+	hasAllow := false
+	for _, singleAllow := range isAllow {
+		hasAllow = hasAllow || singleAllow
+	}
+	if hasAllow == false {
+		return ""
+	}
+	//GET隐含了HEAD，OPTIONS总是能自动应答
+	if isAllow[RouterMethod.GET] {
+		isAllow[RouterMethod.HEAD] = true
+	}
+	//当前方法本身有路由，只是静态目录找不到文件，依然是404
+	if isAllow[methodInt] {
+		return ""
+	}
+	isAllow[RouterMethod.OPTIONS] = true
+
+	entrys := RouterMethod.Entrys()
+	allow := []string{}
+	for i := RouterMethod.HEAD; i <= RouterMethod.PATCH; i++ {
+		if isAllow[i] {
+			allow = append(allow, entrys[i])
+		}
+	}
+	return Implode(allow, ", ")
+}
+
+type routerHeadResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (this *routerHeadResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (this *routerHeadResponseWriter) Flush() {
+	if flusher, isOk := this.ResponseWriter.(http.Flusher); isOk {
+		flusher.Flush()
 	}
-	return handler.notFoundPrefixHandler, nil, handler.notFoundPrefixHandler, nil
 }
 
 func (this *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router, hostParam := this.findHost(r)
+	router.serveHTTP(w, r, hostParam)
+}
+
+func (this *Router) serveHTTP(w http.ResponseWriter, r *http.Request, hostParam RouterParam) {
 	url := r.URL.Path
 	var methodInt int
 	switch r.Method {
@@ -327,27 +524,74 @@ func (this *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	default:
 		panic("unsupport method " + r.Method)
 	}
-	handler, param, notFoundHandler, context := this.findHandler(url, methodInt)
-	status := handler(w, r, param)
+	pathInfo, searchUrl, isExact := this.findPathInfo(url)
+	handler := &pathInfo[methodInt]
+	status := 404
+	routeHandler, param, context := this.findHandler(handler, searchUrl, isExact)
+	if routeHandler == nil && methodInt == RouterMethod.HEAD {
+		//HEAD请求回退到GET，并丢弃body
+		routeHandler, param, context = this.findHandler(&pathInfo[RouterMethod.GET], searchUrl, isExact)
+		if routeHandler != nil {
+			w = &routerHeadResponseWriter{w}
+		}
+	}
+	if routeHandler != nil {
+		status = routeHandler(w, r, combineHostParam(hostParam, param))
+	} else if handler.staticPrefixHandler != nil {
+		status = handler.staticPrefixHandler(w, r, nil)
+		if status == 404 {
+			//静态目录找不到文件，再尝试祖先节点的通配路由
+			routeHandler, param, context = this.findWildcardHandler(handler, searchUrl, true)
+			if routeHandler != nil {
+				status = routeHandler(w, r, combineHostParam(hostParam, param))
+			}
+		}
+	}
 	if status == 404 {
-		notFoundHandler(w, r, param)
+		if this.fallback != nil {
+			this.serveFallback(w, r, methodInt, pathInfo, searchUrl, isExact, param)
+		} else {
+			var isAllow []bool
+			if handler.methodNotAllowedPrefixHandler != nil || handler.optionsPrefixHandler != nil {
+				isAllow = this.findAllowMethod(pathInfo, searchUrl, isExact)
+			}
+			this.serveNotFound(w, r, handler, isAllow, methodInt, param)
+		}
 	}
 	if context != nil {
 		this.pool.Put(context)
 	}
 }
 
+func (this *Router) serveNotFound(w http.ResponseWriter, r *http.Request, handler *routerHandler, isAllow []bool, methodInt int, param RouterParam) {
+	allow := this.formatAllowMethod(isAllow, methodInt)
+	if allow != "" && handler.optionsPrefixHandler != nil {
+		w.Header().Set("Allow", allow)
+		handler.optionsPrefixHandler(w, r, nil)
+	} else if allow != "" && handler.methodNotAllowedPrefixHandler != nil {
+		w.Header().Set("Allow", allow)
+		handler.methodNotAllowedPrefixHandler(w, r, nil)
+	} else {
+		handler.notFoundPrefixHandler(w, r, param)
+	}
+}
+
 type routerConfig struct {
-	method   int
-	priority int
-	path     string
-	handler  interface{}
+	method      int
+	priority    int
+	path        string
+	name        string
+	handlerName string
+	handler     interface{}
 }
 type RouterFactory struct {
 	basePath   string
 	middleware []RouterMiddleware
 	config     []routerConfig
+	lastConfig int
 	group      []*RouterFactory
+	onCreate   []func(router *Router)
+	host       string
 }
 
 type RouterMiddlewareContext struct {
@@ -370,17 +614,24 @@ var RouterMethod struct {
 }
 
 type routerFactoryUrlPrefixHandler struct {
-	param   map[int]string
-	handler interface{}
+	segment    int
+	depth      int
+	param      map[int]string
+	constraint map[int]*regexp.Regexp
+	handler    interface{}
 }
 
 type routerFactoryHandlerFunc func(w http.ResponseWriter, r *http.Request, param RouterParam)
 
 type routerFactoryHandler struct {
-	urlExactHandler       interface{}
-	urlPrefixHandler      map[int]*routerFactoryUrlPrefixHandler
-	staticPrefixHandler   interface{}
-	notFoundPrefixHandler interface{}
+	urlExactHandler               interface{}
+	urlPrefixHandler              map[int][]*routerFactoryUrlPrefixHandler
+	urlWildcardHandler            []*routerFactoryUrlPrefixHandler
+	staticPrefixHandler           interface{}
+	staticDepth                   int
+	notFoundPrefixHandler         interface{}
+	methodNotAllowedPrefixHandler interface{}
+	optionsPrefixHandler          interface{}
 }
 
 type routerFactoryPathInfo map[int]*routerFactoryHandler
@@ -391,6 +642,13 @@ func NewRouterFactory() *RouterFactory {
 		w.WriteHeader(404)
 		w.Write([]byte("404 page not found By Fish"))
 	})
+	routerFactory.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(405)
+		w.Write([]byte("405 method not allowed By Fish"))
+	})
+	//OPTIONS请求自动应答，Allow头部已经由Router设置好了，经过中间件以便CORS处理预检请求
+	routerFactory.addRoute(RouterMethod.OPTIONS, 7, "/", func(w http.ResponseWriter, r *http.Request) {
+	})
 	return routerFactory
 }
 
@@ -417,41 +675,49 @@ func (this *RouterFactory) addRoute(method int, priority int, path string, handl
 }
 
 func (this *RouterFactory) HEAD(path string, handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	this.addRoute(RouterMethod.HEAD, 1, path, handler)
 	return this
 }
 
 func (this *RouterFactory) OPTIONS(path string, handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	this.addRoute(RouterMethod.OPTIONS, 1, path, handler)
 	return this
 }
 
 func (this *RouterFactory) GET(path string, handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	this.addRoute(RouterMethod.GET, 1, path, handler)
 	return this
 }
 
 func (this *RouterFactory) POST(path string, handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	this.addRoute(RouterMethod.POST, 1, path, handler)
 	return this
 }
 
 func (this *RouterFactory) DELETE(path string, handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	this.addRoute(RouterMethod.DELETE, 1, path, handler)
 	return this
 }
 
 func (this *RouterFactory) PUT(path string, handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	this.addRoute(RouterMethod.PUT, 1, path, handler)
 	return this
 }
 
 func (this *RouterFactory) PATCH(path string, handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	this.addRoute(RouterMethod.PATCH, 1, path, handler)
 	return this
 }
 
 func (this *RouterFactory) Any(path string, handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	for i := RouterMethod.HEAD; i <= RouterMethod.PATCH; i++ {
 		this.addRoute(i, 1, path, handler)
 	}
@@ -467,21 +733,36 @@ func (this *RouterFactory) rejustPath(path string) string {
 func (this *RouterFactory) Static(path string, dir string) *RouterFactory {
 	absolutePath := this.rejustPath(this.basePath + "/" + path)
 	handler := http.StripPrefix("/"+absolutePath, http.FileServer(http.Dir(dir)))
+	this.lastConfig = len(this.config)
 	this.addRoute(RouterMethod.HEAD, 3, path, handler)
 	this.addRoute(RouterMethod.GET, 3, path, handler)
+	for i := this.lastConfig; i != len(this.config); i++ {
+		this.config[i].handlerName = "Static(" + dir + ")"
+	}
 	return this
 }
 
 func (this *RouterFactory) NotFound(handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
 	for i := RouterMethod.HEAD; i <= RouterMethod.PATCH; i++ {
 		this.addRoute(i, 4, "/", handler)
 	}
 	return this
 }
 
+func (this *RouterFactory) MethodNotAllowed(handler interface{}) *RouterFactory {
+	this.lastConfig = len(this.config)
+	for i := RouterMethod.HEAD; i <= RouterMethod.PATCH; i++ {
+		this.addRoute(i, 6, "/", handler)
+	}
+	return this
+}
+
 func (this *RouterFactory) Group(basePath string, handler func(r *RouterFactory)) *RouterFactory {
 	realBasePath := this.rejustPath(this.basePath + "/" + basePath)
 	groupFactory := newRouterFactory(realBasePath)
+	groupFactory.host = this.host
+	this.lastConfig = len(this.config)
 
 	this.group = append(this.group, groupFactory)
 
@@ -489,6 +770,53 @@ func (this *RouterFactory) Group(basePath string, handler func(r *RouterFactory)
 	return this
 }
 
+var routerParamType = map[string]string{
+	"int":   "-?[0-9]+",
+	"uint":  "[0-9]+",
+	"float": "-?[0-9]+(\\.[0-9]+)?",
+	"uuid":  "[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}",
+}
+
+func parseUrlParam(segment string) (string, *regexp.Regexp) {
+	//支持:id(int)，:slug([a-z-]+)的参数约束
+	leftIndex := strings.IndexByte(segment, '(')
+	if leftIndex == -1 {
+		return segment, nil
+	}
+	if segment[len(segment)-1] != ')' {
+		panic("invalid path param : " + segment)
+	}
+	name := segment[0:leftIndex]
+	expr := segment[leftIndex+1 : len(segment)-1]
+	if typeExpr, isExist := routerParamType[expr]; isExist {
+		expr = typeExpr
+	}
+	constraint, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		panic("invalid path param : " + segment + "," + err.Error())
+	}
+	return name, constraint
+}
+
+func addUrlPrefixHandler(multiUrlPrefixHandler []*routerFactoryUrlPrefixHandler, urlPrefixHandler *routerFactoryUrlPrefixHandler) []*routerFactoryUrlPrefixHandler {
+	//固定段更多的路由排在前面，同样段数时有约束的路由排在无约束的路由前面
+	insertIndex := len(multiUrlPrefixHandler)
+	for i, singleUrlPrefixHandler := range multiUrlPrefixHandler {
+		if urlPrefixHandler.segment > singleUrlPrefixHandler.segment ||
+			(urlPrefixHandler.segment == singleUrlPrefixHandler.segment &&
+				len(urlPrefixHandler.constraint) != 0 &&
+				len(singleUrlPrefixHandler.constraint) == 0) {
+			insertIndex = i
+			break
+		}
+	}
+	result := make([]*routerFactoryUrlPrefixHandler, 0, len(multiUrlPrefixHandler)+1)
+	result = append(result, multiUrlPrefixHandler[0:insertIndex]...)
+	result = append(result, urlPrefixHandler)
+	result = append(result, multiUrlPrefixHandler[insertIndex:]...)
+	return result
+}
+
 func changeUrlPrefix(maxSegment *int, priority int, path string, handler interface{}) (int, string, interface{}) {
 	//过滤非url逻辑
 	if priority != 1 {
@@ -500,7 +828,8 @@ func changeUrlPrefix(maxSegment *int, priority int, path string, handler interfa
 
 	var singlePathIndex = 0
 	for ; singlePathIndex != len(pathInfo); singlePathIndex++ {
-		if pathInfo[singlePathIndex][0] == ':' {
+		if pathInfo[singlePathIndex][0] == ':' ||
+			pathInfo[singlePathIndex][0] == '*' {
 			break
 		}
 	}
@@ -510,23 +839,38 @@ func changeUrlPrefix(maxSegment *int, priority int, path string, handler interfa
 
 	//处理前缀url逻辑
 	urlPrefixHandler := &routerFactoryUrlPrefixHandler{
-		param:   map[int]string{},
-		handler: handler,
+		segment:    len(pathInfo),
+		param:      map[int]string{},
+		constraint: map[int]*regexp.Regexp{},
+		handler:    handler,
 	}
 	path = Implode(pathInfo[0:singlePathIndex], "/")
 	if len(path) != 0 {
 		path += "/"
 	}
+	isWildcard := false
 	for ; singlePathIndex != len(pathInfo); singlePathIndex++ {
-		if pathInfo[singlePathIndex][0] != ':' {
+		singlePath := pathInfo[singlePathIndex]
+		if singlePath[0] == '*' && singlePathIndex == len(pathInfo)-1 {
+			//尾部通配参数
+			isWildcard = true
+		} else if singlePath[0] != ':' {
 			panic("invalid path : " + path)
 		}
-		urlPrefixHandler.param[singlePathIndex] = pathInfo[singlePathIndex][1:]
+		name, constraint := parseUrlParam(singlePath[1:])
+		urlPrefixHandler.param[singlePathIndex] = name
+		if constraint != nil {
+			urlPrefixHandler.constraint[singlePathIndex] = constraint
+		}
 	}
 
 	if len(pathInfo) > *maxSegment {
 		*maxSegment = len(pathInfo)
 	}
+	if isWildcard {
+		urlPrefixHandler.segment = len(pathInfo) - 1
+		return 5, path, urlPrefixHandler
+	}
 	return 2, path, urlPrefixHandler
 }
 
@@ -549,25 +893,36 @@ func addSingleRoute(tree map[string]routerFactoryPathInfo, maxSegment *int, base
 	methodInfo, isExist := treeInfo[method]
 	if isExist == false {
 		methodInfo = &routerFactoryHandler{
-			urlExactHandler:       nil,
-			urlPrefixHandler:      map[int]*routerFactoryUrlPrefixHandler{},
-			staticPrefixHandler:   nil,
-			notFoundPrefixHandler: nil,
+			urlExactHandler:               nil,
+			urlPrefixHandler:              map[int][]*routerFactoryUrlPrefixHandler{},
+			urlWildcardHandler:            []*routerFactoryUrlPrefixHandler{},
+			staticPrefixHandler:           nil,
+			notFoundPrefixHandler:         nil,
+			methodNotAllowedPrefixHandler: nil,
+			optionsPrefixHandler:          nil,
 		}
 		treeInfo[method] = methodInfo
 	}
 	if priority == 1 {
 		methodInfo.urlExactHandler = handler
 	} else if priority == 2 {
-		methodInfo.urlPrefixHandler[len(pathInfo)] = handler.(*routerFactoryUrlPrefixHandler)
+		methodInfo.urlPrefixHandler[len(pathInfo)] = addUrlPrefixHandler(methodInfo.urlPrefixHandler[len(pathInfo)], handler.(*routerFactoryUrlPrefixHandler))
 	} else if priority == 3 {
 		methodInfo.staticPrefixHandler = handler
+		methodInfo.staticDepth = len(Explode(path, "/"))
 	} else if priority == 4 {
 		methodInfo.notFoundPrefixHandler = handler
+	} else if priority == 5 {
+		handler.(*routerFactoryUrlPrefixHandler).depth = len(Explode(path, "/"))
+		methodInfo.urlWildcardHandler = addUrlPrefixHandler(methodInfo.urlWildcardHandler, handler.(*routerFactoryUrlPrefixHandler))
+	} else if priority == 6 {
+		methodInfo.methodNotAllowedPrefixHandler = handler
+	} else if priority == 7 {
+		methodInfo.optionsPrefixHandler = handler
 	}
 }
 
-func createHandler(middlewares []RouterMiddleware, handler interface{}, path string) routerFactoryHandlerFunc {
+func createHandler(middlewares []RouterMiddleware, handler interface{}, host string, path string, urlBuilder *routerUrlBuilder) routerFactoryHandlerFunc {
 	middlewareContext, isOk := handler.(RouterMiddlewareContext)
 	if isOk == false {
 		middlewareContext = RouterMiddlewareContext{
@@ -575,8 +930,12 @@ func createHandler(middlewares []RouterMiddleware, handler interface{}, path str
 			Handler: handler,
 		}
 	}
-	//设置默认的path参数
+	//设置默认的path与host参数，host为路由分组的域名，没有分组时为空
 	middlewareContext.Data["path"] = path
+	middlewareContext.Data["host"] = host
+	if urlBuilder != nil {
+		middlewareContext.Data["url"] = urlBuilder.URL
+	}
 	middlewares = append(middlewares, NewNoParamMiddleware())
 	curContext := middlewareContext
 	for i := len(middlewares) - 1; i >= 0; i-- {
@@ -596,27 +955,69 @@ func getRegularPath(basePath string, path string) string {
 	return "/" + path
 }
 
-func buildRouterMapRecursive(tree map[string]routerFactoryPathInfo, maxSegment *int, routerFactory *RouterFactory, rootMiddleware []RouterMiddleware) {
+func addUrlName(urlName map[string]string, name string, path string) {
+	existPath, isExist := urlName[name]
+	if isExist && existPath != path {
+		panic("duplicate route name : " + name + " , " + existPath + " and " + path)
+	}
+	urlName[name] = path
+}
+
+type routerBuildInfo struct {
+	tree       map[string]routerFactoryPathInfo
+	maxSegment int
+	urlName    map[string]string
+	routes     []routerRouteInfo
+	onCreate   []func(router *Router)
+	host       []routerBuildHost
+	urlBuilder *routerUrlBuilder
+}
+
+func newRouterBuildInfo() *routerBuildInfo {
+	return &routerBuildInfo{
+		tree:       map[string]routerFactoryPathInfo{},
+		maxSegment: 0,
+		urlName:    map[string]string{},
+		routes:     []routerRouteInfo{},
+		onCreate:   []func(router *Router){},
+		host:       []routerBuildHost{},
+		urlBuilder: &routerUrlBuilder{},
+	}
+}
+
+func buildRouterMapRecursive(buildInfo *routerBuildInfo, routerFactory *RouterFactory, rootMiddleware []RouterMiddleware) {
 	middlewares := []RouterMiddleware{}
 	middlewares = append(middlewares, rootMiddleware...)
 	middlewares = append(middlewares, routerFactory.middleware...)
 
 	for _, config := range routerFactory.config {
 		inputPath := getRegularPath(routerFactory.basePath, config.path)
-		wrapperHandler := createHandler(middlewares, config.handler, inputPath)
-		addSingleRoute(tree, maxSegment, routerFactory.basePath, config.method, config.priority, config.path, wrapperHandler)
+		wrapperHandler := createHandler(middlewares, config.handler, routerFactory.host, inputPath, buildInfo.urlBuilder)
+		addSingleRoute(buildInfo.tree, &buildInfo.maxSegment, routerFactory.basePath, config.method, config.priority, config.path, wrapperHandler)
+		addRouteInfo(&buildInfo.routes, middlewares, config, inputPath)
+		if config.name != "" {
+			addUrlName(buildInfo.urlName, config.name, inputPath)
+		}
 	}
+	buildInfo.onCreate = append(buildInfo.onCreate, routerFactory.onCreate...)
 
 	for _, singleGroup := range routerFactory.group {
-		buildRouterMapRecursive(tree, maxSegment, singleGroup, middlewares)
+		if singleGroup.host != routerFactory.host {
+			//域名路由单独建树
+			buildInfo.host = append(buildInfo.host, routerBuildHost{
+				factory:     singleGroup,
+				middlewares: middlewares,
+			})
+			continue
+		}
+		buildRouterMapRecursive(buildInfo, singleGroup, middlewares)
 	}
 }
 
-func buildRouterMap(routerFactory *RouterFactory) (map[string]routerFactoryPathInfo, int) {
-	tree := map[string]routerFactoryPathInfo{}
-	maxSegment := 0
-	buildRouterMapRecursive(tree, &maxSegment, routerFactory, []RouterMiddleware{})
-	return tree, maxSegment
+func buildRouterMap(routerFactory *RouterFactory) *routerBuildInfo {
+	buildInfo := newRouterBuildInfo()
+	buildRouterMapRecursive(buildInfo, routerFactory, []RouterMiddleware{})
+	return buildInfo
 }
 
 func buildRouterTrie(tree map[string]routerFactoryPathInfo) *TrieTree {
@@ -627,13 +1028,39 @@ func buildRouterTrie(tree map[string]routerFactoryPathInfo) *TrieTree {
 	return trieTree
 }
 
-func (this *RouterFactory) Create() *Router {
-	tree, maxSegment := buildRouterMap(this)
-	trieTree := buildRouterTrie(tree)
-	router := newRouter(trieTree, maxSegment)
+func createRouter(buildInfo *routerBuildInfo, oldRouter *Router) *Router {
+	conflict := checkRouteConflict(buildInfo.routes)
+	if len(conflict) != 0 {
+		panic("router conflict :\n" + Implode(conflict, "\n"))
+	}
+	trieTree := buildRouterTrie(buildInfo.tree)
+	return newRouter(trieTree, buildInfo, oldRouter)
+}
+
+func (this *RouterFactory) create(oldRouter *Router) *Router {
+	buildInfo := buildRouterMap(this)
+	router := createRouter(buildInfo, oldRouter)
+	router.host = createHostRouter(router, buildInfo, oldRouter)
+	for _, singleHost := range router.host {
+		for name, segments := range singleHost.router.url {
+			if _, isExist := router.url[name]; isExist {
+				panic("duplicate route name : " + name)
+			}
+			router.url[name] = segments
+		}
+	}
+	//url生成函数在其他回调之前绑定，回调里面可以直接生成url
+	buildInfo.urlBuilder.router = router
+	for _, onCreate := range buildInfo.onCreate {
+		onCreate(router)
+	}
 	return router
 }
 
+func (this *RouterFactory) Create() *Router {
+	return this.create(nil)
+}
+
 func init() {
 	InitEnumStruct(&RouterMethod)
 }
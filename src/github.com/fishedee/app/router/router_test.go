@@ -5,6 +5,7 @@ import (
 	. "github.com/fishedee/assert"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -116,7 +117,6 @@ func TestRouterUrl(t *testing.T) {
 				[]interface{}{4, "/"},
 				[]interface{}{3, "/"},
 
-				[]interface{}{1, "/a/b/c"},
 				[]interface{}{1, "/a/b/c"},
 				[]interface{}{3, "/a/b/c"},
 				[]interface{}{4, "/a/b/c"},
@@ -259,8 +259,12 @@ func TestRouterMethod(t *testing.T) {
 			if singleTestCase.findData == "Any" ||
 				method == singleTestCase.findData {
 				AssertEqual(t, w.Read(), "bingo")
+			} else if method == "HEAD" && singleTestCase.findData == "GET" {
+				AssertEqual(t, w.Read(), "")
+			} else if method == "OPTIONS" {
+				AssertEqual(t, w.Read(), "")
 			} else {
-				AssertEqual(t, w.Read(), "404")
+				AssertEqual(t, w.Read(), "405 method not allowed By Fish")
 			}
 		}
 	}
@@ -283,14 +287,18 @@ func TestRouterMethod2(t *testing.T) {
 		{"GET", "/", "/"},
 		{"POST", "/", "/"},
 		{"GET", "/a", "/a"},
-		{"POST", "/a", "404"},
-		{"GET", "/ab", "404"},
+		{"POST", "/a", "405"},
+		{"GET", "/ab", "405"},
 		{"POST", "/ab", "/ab"},
+		{"GET", "/abc", "404"},
 	}
 	routerFactory := NewRouterFactory()
 	routerFactory.NotFound(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("404"))
 	})
+	routerFactory.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("405"))
+	})
 	for _, singleTestData := range testData {
 		func(url string) {
 			singleTestData.insertData(routerFactory, singleTestData.url, func(w http.ResponseWriter, r *http.Request) {
@@ -307,6 +315,90 @@ func TestRouterMethod2(t *testing.T) {
 	}
 }
 
+func TestRouterMethodNotAllowed(t *testing.T) {
+	routerFactory := NewRouterFactory()
+	routerFactory.GET("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Method", r.Method)
+		w.Write([]byte("get_a"))
+	})
+	routerFactory.POST("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("post_a"))
+	})
+	routerFactory.PUT("/b/:id(int)", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("put_b"))
+	})
+	routerFactory.OPTIONS("/b/:id(int)", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("options_b"))
+	})
+	routerFactory.Static("/static", "./testdata")
+
+	testCase := []struct {
+		method string
+		url    string
+		status int
+		allow  string
+		data   string
+	}{
+		{"GET", "/a", 200, "", "get_a"},
+		{"HEAD", "/a", 200, "", ""},
+		{"POST", "/a", 200, "", "post_a"},
+		{"DELETE", "/a", 405, "HEAD, OPTIONS, GET, POST", "405 method not allowed By Fish"},
+		{"OPTIONS", "/a", 200, "HEAD, OPTIONS, GET, POST", ""},
+		{"PUT", "/b/123", 200, "", "put_b"},
+		{"OPTIONS", "/b/123", 200, "", "options_b"},
+		{"GET", "/b/123", 405, "OPTIONS, PUT", "405 method not allowed By Fish"},
+		{"GET", "/b/abc", 404, "", "404 page not found By Fish"},
+		{"DELETE", "/c", 404, "", "404 page not found By Fish"},
+		{"GET", "/static/a.html", 200, "", "hello a"},
+		{"POST", "/static/a.html", 405, "HEAD, OPTIONS, GET", "405 method not allowed By Fish"},
+		{"OPTIONS", "/static/a.html", 200, "HEAD, OPTIONS, GET", ""},
+	}
+	router := routerFactory.Create()
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest(singleTestCase.method, singleTestCase.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		AssertEqual(t, w.Header().Get("Allow"), singleTestCase.allow, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.data, index)
+		if singleTestCase.method == "HEAD" {
+			//HEAD请求由GET的handler处理
+			AssertEqual(t, w.Header().Get("X-Method"), singleTestCase.method, index)
+		}
+	}
+}
+
+func TestRouterMethodNotAllowedCustom(t *testing.T) {
+	routerFactory := NewRouterFactory()
+	routerFactory.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(405)
+		w.Write([]byte("custom"))
+	})
+	routerFactory.GET("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get_a"))
+	})
+
+	testCase := []struct {
+		method string
+		url    string
+		status int
+		allow  string
+		data   string
+	}{
+		{"POST", "/a", 405, "HEAD, OPTIONS, GET", "custom"},
+		{"OPTIONS", "/a", 200, "HEAD, OPTIONS, GET", ""},
+	}
+	router := routerFactory.Create()
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest(singleTestCase.method, singleTestCase.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Code, singleTestCase.status, index)
+		AssertEqual(t, w.Header().Get("Allow"), singleTestCase.allow, index)
+		AssertEqual(t, w.Body.String(), singleTestCase.data, index)
+	}
+}
+
 func TestRouterStatic(t *testing.T) {
 	routerFactory := NewRouterFactory()
 	routerFactory.NotFound(func(w http.ResponseWriter, r *http.Request) {
@@ -422,6 +514,169 @@ func TestRouterUrlPrefixParam(t *testing.T) {
 	}
 }
 
+func TestRouterUrlParamConstraint(t *testing.T) {
+	insertData := []string{
+		"/user/:userId(int)",
+		"/user/:name",
+		"/user/:userId(int)/:slug([a-z-]+)",
+		"/order/:orderId(uuid)",
+		"/mc/:id1/:id2",
+		"/mc/10001/:id3(uint)",
+	}
+	findData := []struct {
+		url   string
+		route string
+		param RouterParam
+	}{
+		{"/user/123", "/user/:userId(int)", RouterParam{
+			{"userId", "123"},
+		}},
+		{"/user/-5", "/user/:userId(int)", RouterParam{
+			{"userId", "-5"},
+		}},
+		{"/user/fish", "/user/:name", RouterParam{
+			{"name", "fish"},
+		}},
+		{"/user/123/hello-fish", "/user/:userId(int)/:slug([a-z-]+)", RouterParam{
+			{"userId", "123"},
+			{"slug", "hello-fish"},
+		}},
+		{"/user/123/Hello", "404", nil},
+		{"/user/fish/hello", "404", nil},
+		{"/order/0f8fad5b-d9cb-469f-a165-70867728950e", "/order/:orderId(uuid)", RouterParam{
+			{"orderId", "0f8fad5b-d9cb-469f-a165-70867728950e"},
+		}},
+		{"/order/123", "404", nil},
+		{"/mc/10001/456", "/mc/10001/:id3(uint)", RouterParam{
+			{"id3", "456"},
+		}},
+		{"/mc/10001/abc", "/mc/:id1/:id2", RouterParam{
+			{"id1", "10001"},
+			{"id2", "abc"},
+		}},
+	}
+
+	routerFactory := NewRouterFactory()
+	routerFactory.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("404"))
+	})
+	check := make(chan RouterParam, 10)
+	for _, data := range insertData {
+		func(data string) {
+			routerFactory.GET(data, func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				w.Write([]byte(data))
+				check <- append(RouterParam{}, param...)
+			})
+		}(data)
+	}
+	router := routerFactory.Create()
+	for _, data := range findData {
+		r, _ := http.NewRequest("GET", data.url, nil)
+		w := &fakeWriter{}
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Read(), data.route, data)
+		select {
+		case result := <-check:
+			AssertEqual(t, result, data.param, data)
+		default:
+			AssertEqual(t, data.param, RouterParam(nil), data)
+		}
+	}
+}
+
+func TestRouterUrlWildcard(t *testing.T) {
+	insertData := []string{
+		"/*path",
+		"/file",
+		"/file/:name",
+		"/file/*path",
+		"/file/:bucket(int)/*path",
+		"/proxy/*path",
+		"/proxy/api/login",
+	}
+	findData := []struct {
+		url   string
+		route string
+		param RouterParam
+	}{
+		{"/index.html", "/*path", RouterParam{
+			{"path", "index.html"},
+		}},
+		{"/user/10001/detail", "/*path", RouterParam{
+			{"path", "user/10001/detail"},
+		}},
+		{"/file", "/file", nil},
+		{"/file/a.txt", "/file/:name", RouterParam{
+			{"name", "a.txt"},
+		}},
+		{"/file/dir/a.txt", "/file/*path", RouterParam{
+			{"path", "dir/a.txt"},
+		}},
+		{"/file/123/dir/a.txt", "/file/:bucket(int)/*path", RouterParam{
+			{"bucket", "123"},
+			{"path", "dir/a.txt"},
+		}},
+		{"/proxy", "/proxy/*path", RouterParam{
+			{"path", ""},
+		}},
+		{"/proxy/", "/proxy/*path", RouterParam{
+			{"path", ""},
+		}},
+		{"/proxy/api/login", "/proxy/api/login", nil},
+		{"/proxy/api/login/mc", "/proxy/*path", RouterParam{
+			{"path", "api/login/mc"},
+		}},
+		{"/proxy//api//user", "/proxy/*path", RouterParam{
+			{"path", "api//user"},
+		}},
+	}
+
+	routerFactory := NewRouterFactory()
+	check := make(chan RouterParam, 10)
+	for _, data := range insertData {
+		func(data string) {
+			routerFactory.GET(data, func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+				w.Write([]byte(data))
+				check <- append(RouterParam(nil), param...)
+			})
+		}(data)
+	}
+	router := routerFactory.Create()
+	for _, data := range findData {
+		r, _ := http.NewRequest("GET", data.url, nil)
+		w := &fakeWriter{}
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Read(), data.route, data)
+		AssertEqual(t, <-check, data.param, data)
+	}
+}
+
+func TestRouterWildcardStatic(t *testing.T) {
+	routerFactory := NewRouterFactory()
+	routerFactory.Static("/assets", "./testdata")
+	routerFactory.GET("/*path", func(w http.ResponseWriter, r *http.Request, param RouterParam) {
+		w.Write([]byte("index_" + param[0].Value))
+	})
+	router := routerFactory.Create()
+
+	testCase := []struct {
+		url  string
+		data string
+	}{
+		{"/assets/a.html", "hello a"},
+		{"/assets/c/d.html", "hello d"},
+		{"/assets/e.html", "index_assets/e.html"},
+		{"/user/list", "index_user/list"},
+		{"/", "index_"},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("GET", singleTestCase.url, nil)
+		w := &fakeWriter{}
+		router.ServeHTTP(w, r)
+		AssertEqual(t, w.Read(), singleTestCase.data, index)
+	}
+}
+
 func TestRouterMiddleware(t *testing.T) {
 	newMiddleware := func(data string) RouterMiddleware {
 		return func(prev RouterMiddlewareContext) RouterMiddlewareContext {
@@ -0,0 +1,137 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/fishedee/language"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type routerUrlSegment struct {
+	value      string
+	isParam    bool
+	isWildcard bool
+	constraint *regexp.Regexp
+}
+
+type routerUrlBuilder struct {
+	router *Router
+}
+
+func (this *RouterFactory) Name(name string) *RouterFactory {
+	//给最近一次注册的路由命名，Any注册的多个方法共用一个名字
+	if this.lastConfig == len(this.config) {
+		panic("route name must follow a route : " + name)
+	}
+	for i := this.lastConfig; i != len(this.config); i++ {
+		if this.config[i].priority != 1 {
+			panic("route name must follow a route : " + name)
+		}
+		this.config[i].name = name
+	}
+	return this
+}
+
+func (this *routerUrlBuilder) URL(name string, params ...interface{}) (string, error) {
+	//中间件在Router创建之前就拿到了url生成函数，Router创建以后才绑定
+	if this.router == nil {
+		return "", errors.New("router has not been created")
+	}
+	return this.router.URL(name, params...)
+}
+
+func (this *Router) buildUrl(urlName map[string]string) map[string][]routerUrlSegment {
+	result := map[string][]routerUrlSegment{}
+	for name, path := range urlName {
+		segments := []routerUrlSegment{}
+		for _, singlePath := range Explode(path, "/") {
+			if singlePath[0] != ':' && singlePath[0] != '*' {
+				segments = append(segments, routerUrlSegment{
+					value: singlePath,
+				})
+				continue
+			}
+			paramName, constraint := parseUrlParam(singlePath[1:])
+			segments = append(segments, routerUrlSegment{
+				value:      paramName,
+				isParam:    true,
+				isWildcard: singlePath[0] == '*',
+				constraint: constraint,
+			})
+		}
+		result[name] = segments
+	}
+	return result
+}
+
+func (this *Router) URL(name string, params ...interface{}) (string, error) {
+	segments, isExist := this.url[name]
+	if isExist == false {
+		return "", fmt.Errorf("route name [%v] does not exist", name)
+	}
+	if len(params)%2 != 0 {
+		return "", errors.New("url params must be key value pairs")
+	}
+	paramMap := map[string]string{}
+	paramKey := []string{}
+	for i := 0; i != len(params); i += 2 {
+		key, isOk := params[i].(string)
+		if isOk == false {
+			return "", fmt.Errorf("url param key must be string,current is [%v]", params[i])
+		}
+		paramMap[key] = fmt.Sprintf("%v", params[i+1])
+		paramKey = append(paramKey, key)
+	}
+
+	result := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment.isParam == false {
+			result = append(result, segment.value)
+			continue
+		}
+		value, isExist := paramMap[segment.value]
+		if isExist == false {
+			return "", fmt.Errorf("route [%v] missing param [%v]", name, segment.value)
+		}
+		delete(paramMap, segment.value)
+		if segment.constraint != nil && segment.constraint.MatchString(value) == false {
+			return "", fmt.Errorf("route [%v] param [%v] does not match constraint [%v]", name, segment.value, segment.constraint.String())
+		}
+		if segment.isWildcard {
+			valueInfo := strings.Split(value, "/")
+			for i, singleValue := range valueInfo {
+				valueInfo[i] = url.PathEscape(singleValue)
+			}
+			result = append(result, Implode(valueInfo, "/"))
+		} else {
+			if value == "" {
+				return "", fmt.Errorf("route [%v] param [%v] is empty", name, segment.value)
+			}
+			result = append(result, url.PathEscape(value))
+		}
+	}
+	path := "/" + Implode(result, "/")
+
+	//多余的参数放在query上
+	query := url.Values{}
+	for _, key := range paramKey {
+		value, isExist := paramMap[key]
+		if isExist {
+			query.Set(key, value)
+		}
+	}
+	if len(query) != 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+func (this *Router) MustURL(name string, params ...interface{}) string {
+	result, err := this.URL(name, params...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
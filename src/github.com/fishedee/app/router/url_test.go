@@ -0,0 +1,81 @@
+package router
+
+import (
+	. "github.com/fishedee/assert"
+	"net/http"
+	"testing"
+)
+
+func TestRouterNameUrl(t *testing.T) {
+	doNothing := func(w http.ResponseWriter, r *http.Request) {
+	}
+	routerFactory := NewRouterFactory()
+	routerFactory.GET("/", doNothing).Name("home")
+	routerFactory.Group("/user", func(routerFactory2 *RouterFactory) {
+		routerFactory2.GET("/:userId(int)", doNothing).Name("user.detail")
+		routerFactory2.Any("/:userId/:name", doNothing).Name("user.name")
+	})
+	routerFactory.GET("/file/*path", doNothing).Name("file")
+	router := routerFactory.Create()
+
+	testCase := []struct {
+		name   string
+		params []interface{}
+		url    string
+		hasErr bool
+	}{
+		{"home", nil, "/", false},
+		{"user.detail", []interface{}{"userId", 123}, "/user/123", false},
+		{"user.detail", []interface{}{"userId", 123, "from", "mail", "a", "b c"}, "/user/123?a=b+c&from=mail", false},
+		{"user.detail", []interface{}{"userId", "abc"}, "", true},
+		{"user.detail", []interface{}{}, "", true},
+		{"user.detail", []interface{}{"userId"}, "", true},
+		{"user.name", []interface{}{"userId", "a/b", "name", "鱼"}, "/user/a%2Fb/%E9%B1%BC", false},
+		{"user.name", []interface{}{"userId", "", "name", "fish"}, "", true},
+		{"file", []interface{}{"path", "a b/c.txt"}, "/file/a%20b/c.txt", false},
+		{"file2", nil, "", true},
+	}
+	for index, singleTestCase := range testCase {
+		url, err := router.URL(singleTestCase.name, singleTestCase.params...)
+		AssertEqual(t, url, singleTestCase.url, index)
+		AssertEqual(t, err != nil, singleTestCase.hasErr, index)
+	}
+	AssertEqual(t, router.MustURL("user.detail", "userId", 10001), "/user/10001")
+
+	_, err := router.URL("user.detail", "userId", "abc")
+	AssertEqual(t, err.Error(), "route [user.detail] param [userId] does not match constraint [^(?:-?[0-9]+)$]")
+}
+
+func TestRouterNameLastRoute(t *testing.T) {
+	doNothing := func(w http.ResponseWriter, r *http.Request) {
+	}
+	var urlBuilder func(name string, params ...interface{}) (string, error)
+	routerFactory := NewRouterFactory()
+	routerFactory.Use(func(prev RouterMiddlewareContext) RouterMiddlewareContext {
+		urlBuilder = prev.Data["url"].(func(name string, params ...interface{}) (string, error))
+		return prev
+	})
+	routerFactory.GET("/a", doNothing).Name("a.get")
+	routerFactory.POST("/a", doNothing).Name("a.post")
+	routerFactory.Any("/b/:id", doNothing).Name("b")
+	//OnCreate的回调里面已经可以生成url
+	var onCreateUrl string
+	routerFactory.OnCreate(func(router *Router) {
+		onCreateUrl, _ = urlBuilder("a.get")
+	})
+
+	router := routerFactory.Create()
+	AssertEqual(t, onCreateUrl, "/a")
+	routes := router.Routes()
+	AssertEqual(t, routes[0].Name, "a.get")
+	AssertEqual(t, routes[1].Name, "a.post")
+	for _, route := range routes[2:] {
+		AssertEqual(t, route.Name, "b")
+	}
+	AssertEqual(t, router.MustURL("a.get"), "/a")
+	AssertEqual(t, router.MustURL("a.post"), "/a")
+
+	url, err := urlBuilder("b", "id", 1)
+	AssertEqual(t, url, "/b/1")
+	AssertEqual(t, err, nil)
+}
@@ -1,6 +1,7 @@
 package sqlf
 
 import (
+	"context"
 	gosql "database/sql"
 	. "github.com/fishedee/app/log"
 	. "github.com/fishedee/app/metric"
@@ -42,6 +43,8 @@ type SqlfDB interface {
 	Begin() (SqlfTx, error)
 	MustBegin() SqlfTx
 
+	WithContext(ctx context.Context) SqlfDB
+
 	Close() error
 	MustClose()
 }
@@ -101,6 +104,7 @@ func NewSqlfDB(log Log, metric Metric, config SqlfDBConfig) (SqlfDB, error) {
 	}
 	return &dbImplement{
 		db:      db,
+		ctx:     context.Background(),
 		log:     log,
 		isDebug: isDebug,
 		driver:  config.Driver,
@@ -135,6 +139,7 @@ func metricSqlf(db *gosql.DB, metric Metric) {
 
 type dbImplement struct {
 	db      *gosql.DB
+	ctx     context.Context
 	log     Log
 	isDebug bool
 	driver  string
@@ -146,7 +151,7 @@ func (this *dbImplement) Query(data interface{}, query string, args ...interface
 		if err != nil {
 			return query, err
 		}
-		rows, err := this.db.Query(sql, args...)
+		rows, err := this.db.QueryContext(this.ctx, sql, args...)
 		if err != nil {
 			return sql, err
 		}
@@ -176,7 +181,7 @@ func (this *dbImplement) Exec(query string, args ...interface{}) (SqlfResult, er
 			return query, err
 		}
 
-		result, err := this.db.Exec(sql, args...)
+		result, err := this.db.ExecContext(this.ctx, sql, args...)
 		if err != nil {
 			return sql, err
 		}
@@ -199,12 +204,14 @@ func (this *dbImplement) MustExec(query string, args ...interface{}) SqlfResult
 }
 
 func (this *dbImplement) Begin() (SqlfTx, error) {
-	tx, err := this.db.Begin()
+	//context取消时事务会自动回滚
+	tx, err := this.db.BeginTx(this.ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	return &txImplement{
 		tx:          tx,
+		ctx:         this.ctx,
 		isDebug:     this.isDebug,
 		log:         this.log,
 		driver:      this.driver,
@@ -221,6 +228,17 @@ func (this *dbImplement) MustBegin() SqlfTx {
 	return tx
 }
 
+func (this *dbImplement) WithContext(ctx context.Context) SqlfDB {
+	//调试日志带上请求ID，查询在context取消或超时的时候中断，事务沿用开启事务时的context
+	return &dbImplement{
+		db:      this.db,
+		ctx:     ctx,
+		log:     this.log.WithContext(ctx),
+		isDebug: this.isDebug,
+		driver:  this.driver,
+	}
+}
+
 func (this *dbImplement) Close() error {
 	return this.db.Close()
 }
@@ -262,6 +280,7 @@ func (this *resultImplement) MustRowsAffected() int64 {
 
 type txImplement struct {
 	tx          *gosql.Tx
+	ctx         context.Context
 	log         Log
 	driver      string
 	isDebug     bool
@@ -275,7 +294,7 @@ func (this *txImplement) Query(data interface{}, query string, args ...interface
 		if err != nil {
 			return query, err
 		}
-		rows, err := this.tx.Query(sql, args...)
+		rows, err := this.tx.QueryContext(this.ctx, sql, args...)
 		if err != nil {
 			return sql, err
 		}
@@ -305,7 +324,7 @@ func (this *txImplement) Exec(query string, args ...interface{}) (SqlfResult, er
 			return query, err
 		}
 
-		result, err := this.tx.Exec(sql, args...)
+		result, err := this.tx.ExecContext(this.ctx, sql, args...)
 		if err != nil {
 			return sql, err
 		}
@@ -1,6 +1,7 @@
 package sqlf
 
 import (
+	"context"
 	"encoding/json"
 	. "github.com/fishedee/app/log"
 	. "github.com/fishedee/assert"
@@ -539,3 +540,25 @@ func TestAll(t *testing.T) {
 	testAll(t, initSqliteDatabase)
 	testAll(t, initMySqlDatabase)
 }
+
+func TestContext(t *testing.T) {
+	db := initSqliteDatabase()
+	ctx, cancel := context.WithCancel(context.Background())
+	ctxDb := db.WithContext(ctx)
+
+	var users []User
+	err := ctxDb.Query(&users, "select ?.column from t_user", users)
+	AssertEqual(t, err, nil)
+
+	cancel()
+
+	_, err = ctxDb.Exec("delete from t_user")
+	AssertEqual(t, err, context.Canceled)
+	err = ctxDb.Query(&users, "select ?.column from t_user", users)
+	AssertEqual(t, err, context.Canceled)
+	_, err = ctxDb.Begin()
+	AssertEqual(t, err, context.Canceled)
+
+	//不影响原来的db
+	db.MustQuery(&users, "select ?.column from t_user", users)
+}
@@ -0,0 +1,238 @@
+package validator
+
+import (
+	"fmt"
+	validator_ "gopkg.in/bluesuncorp/validator.v5"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	validatorRule       = newValidatorRule()
+	validatorRuleType   sync.Map
+	validatorRuleRegexp sync.Map
+	validatorTimeType   = reflect.TypeOf(time.Time{})
+	validatorFieldRule  = map[string]string{
+		"eqfield":  "must be equal to",
+		"nefield":  "must not be equal to",
+		"gtfield":  "must be greater than",
+		"gtefield": "must be greater than or equal to",
+		"ltfield":  "must be less than",
+		"ltefield": "must be less than or equal to",
+	}
+)
+
+func getValidatorRuneFunc(sizeFunc validator_.Func) validator_.Func {
+	return func(top interface{}, current interface{}, field interface{}, param string) bool {
+		//字符串的长度按字符计算，而不是字节
+		if value, isOk := field.(string); isOk {
+			field = []rune(value)
+		}
+		return sizeFunc(top, current, field, param)
+	}
+}
+
+func isValidatorOneOf(top interface{}, current interface{}, field interface{}, param string) bool {
+	value := fmt.Sprintf("%v", field)
+	for _, single := range strings.Fields(param) {
+		if single == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidatorRegexp(top interface{}, current interface{}, field interface{}, param string) bool {
+	//正则表达式写错属于代码的错误，直接panic
+	expr, isExist := validatorRuleRegexp.Load(param)
+	if isExist == false {
+		expr, _ = validatorRuleRegexp.LoadOrStore(param, regexp.MustCompile(param))
+	}
+	value, isOk := field.(string)
+	if isOk == false {
+		return false
+	}
+	return expr.(*regexp.Regexp).MatchString(value)
+}
+
+func newValidatorRule() *validator_.Validate {
+	//复制一份内置的规则，不修改库的全局变量
+	funcs := map[string]validator_.Func{}
+	for name, single := range validator_.BakedInValidators {
+		funcs[name] = single
+	}
+	for _, name := range []string{"min", "max", "len"} {
+		funcs[name] = getValidatorRuneFunc(funcs[name])
+	}
+	funcs["oneof"] = isValidatorOneOf
+	funcs["regexp"] = isValidatorRegexp
+	return validator_.New("validate", funcs)
+}
+
+func warmValidatorRule(t reflect.Type, visit map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == validatorTimeType || visit[t] {
+		return
+	}
+	visit[t] = true
+	if _, isExist := validatorRuleType.Load(t); isExist {
+		return
+	}
+	//库按第一次遇到的值缓存字段，空指针的结构体字段会被永久跳过，所以先用非空的值检查一遍
+	//内层的结构体先检查，外层检查时内层已经在库的缓存里面
+	for i := 0; i != t.NumField(); i++ {
+		warmValidatorRule(t.Field(i).Type, visit)
+	}
+	value := reflect.New(t).Elem()
+	for i := 0; i != t.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct && field.CanSet() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+	}
+	//规则写错时库会panic，属于代码的错误，第一次检查这个类型时就会暴露出来
+	validatorRule.Struct(value.Interface())
+	validatorRuleType.Store(t, true)
+}
+
+func getValidatorFieldName(field reflect.StructField) string {
+	//与json的字段名一致，没有json标签时首字母小写
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name != "" && name != "-" {
+		return name
+	}
+	return strings.ToLower(field.Name[0:1]) + field.Name[1:]
+}
+
+func getValidatorRuleMessage(fieldError *validator_.FieldError, parent reflect.Type) string {
+	unit := ""
+	if fieldError.Kind == reflect.String {
+		unit = " characters"
+	} else if fieldError.Kind == reflect.Slice || fieldError.Kind == reflect.Array || fieldError.Kind == reflect.Map {
+		unit = " items"
+	}
+	switch fieldError.Tag {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fieldError.Param + unit
+	case "max":
+		return "must be at most " + fieldError.Param + unit
+	case "len":
+		return "must be exactly " + fieldError.Param + unit
+	case "email":
+		return "must be a valid email"
+	case "oneof":
+		return "must be one of [" + fieldError.Param + "]"
+	case "regexp":
+		return "format is invalid"
+	}
+	if message, isExist := validatorFieldRule[fieldError.Tag]; isExist {
+		otherName := fieldError.Param
+		if otherField, isExist := parent.FieldByName(fieldError.Param); isExist {
+			otherName = getValidatorFieldName(otherField)
+		}
+		return message + " " + otherName
+	}
+	if fieldError.Param != "" {
+		return "does not match " + fieldError.Tag + "=" + fieldError.Param
+	}
+	return "does not match " + fieldError.Tag
+}
+
+func getValidatorElemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func getValidatorElemMessage(err error, elemType reflect.Type, name string, parent reflect.Type, result *[]string) {
+	if fieldError, isOk := err.(*validator_.FieldError); isOk {
+		getValidatorFieldMessage(fieldError, elemType, name, parent, result)
+	} else if structError, isOk := err.(*validator_.StructErrors); isOk {
+		getValidatorStructMessage(structError, elemType, name+".", result)
+	}
+}
+
+func getValidatorFieldMessage(fieldError *validator_.FieldError, fieldType reflect.Type, name string, parent reflect.Type, result *[]string) {
+	elemType := getValidatorElemType(fieldType)
+	if fieldError.IsPlaceholderErr && fieldError.IsSliceOrArray {
+		//dive的错误按下标顺序输出
+		index := []int{}
+		for single := range fieldError.SliceOrArrayErrs {
+			index = append(index, single)
+		}
+		sort.Ints(index)
+		for _, single := range index {
+			getValidatorElemMessage(fieldError.SliceOrArrayErrs[single], elemType.Elem(), name+"["+strconv.Itoa(single)+"]", parent, result)
+		}
+		return
+	} else if fieldError.IsPlaceholderErr && fieldError.IsMap {
+		keys := []string{}
+		keyMap := map[string]interface{}{}
+		for single := range fieldError.MapErrs {
+			key := fmt.Sprintf("%v", single)
+			keys = append(keys, key)
+			keyMap[key] = single
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			getValidatorElemMessage(fieldError.MapErrs[keyMap[key]], elemType.Elem(), name+"["+key+"]", parent, result)
+		}
+		return
+	}
+	//没有规则的空指针结构体，库也会返回一个没有tag的错误，忽略掉
+	if fieldError.Tag == "" {
+		return
+	}
+	*result = append(*result, name+" "+getValidatorRuleMessage(fieldError, parent))
+}
+
+func getValidatorStructMessage(structError *validator_.StructErrors, t reflect.Type, prefix string, result *[]string) {
+	//库返回的是map，按字段的顺序输出，字段名使用json的名字
+	t = getValidatorElemType(t)
+	for i := 0; i != t.NumField(); i++ {
+		field := t.Field(i)
+		name := prefix + getValidatorFieldName(field)
+		if fieldError, isExist := structError.Errors[field.Name]; isExist {
+			getValidatorFieldMessage(fieldError, field.Type, name, t, result)
+		}
+		if childError, isExist := structError.StructErrors[field.Name]; isExist {
+			if field.Anonymous {
+				//匿名结构体的字段当作外层的字段
+				getValidatorStructMessage(childError, field.Type, prefix, result)
+			} else {
+				getValidatorStructMessage(childError, field.Type, name+".", result)
+			}
+		}
+	}
+}
+
+func validateRule(obj interface{}) []string {
+	value := reflect.ValueOf(obj)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct || value.Type() == validatorTimeType {
+		return nil
+	}
+	warmValidatorRule(value.Type(), map[reflect.Type]bool{})
+	structError := validatorRule.Struct(value.Interface())
+	if structError == nil {
+		return nil
+	}
+	result := []string{}
+	getValidatorStructMessage(structError, value.Type(), "", &result)
+	return result
+}
@@ -48,6 +48,8 @@ type Validator interface {
 	MustBindJson(obj interface{})
 	Bind(obj interface{}) error
 	MustBind(obj interface{})
+	Validate(obj interface{}) error
+	MustValidate(obj interface{})
 
 	//元信息
 	Request() *http.Request
@@ -75,6 +77,7 @@ type ValidatorConfig struct {
 	MaxFormSize       int `config:"maxformsize"`
 	MaxFileSize       int `config:"maxfilesize"`
 	MaxFileMemorySize int `config:"maxfilememorysize"`
+	ValidateCode      int `config:"validatecode"`
 }
 
 type validatorFactoryImplement struct {
@@ -117,6 +120,9 @@ func newValidator(r *http.Request, param map[string]string, config ValidatorConf
 	if config.MaxFileMemorySize <= 0 {
 		config.MaxFileMemorySize = 1024 * 1024 * 10
 	}
+	if config.ValidateCode == 0 {
+		config.ValidateCode = 1
+	}
 	return &validatorImplement{
 		request:      r,
 		param:        param,
@@ -339,21 +345,14 @@ func (this *validatorImplement) MustFile(key string) *multipart.FileHeader {
 	return result
 }
 
-func (this *validatorImplement) BindParam(obj interface{}) error {
+func (this *validatorImplement) bindParam(obj interface{}) error {
 	if len(this.param) == 0 {
 		return nil
 	}
 	return MapToArray(this.param, obj, "validator")
 }
 
-func (this *validatorImplement) MustBindParam(obj interface{}) {
-	err := this.BindParam(obj)
-	if err != nil {
-		Throw(1, err.Error())
-	}
-}
-
-func (this *validatorImplement) BindQuery(obj interface{}) error {
+func (this *validatorImplement) bindQuery(obj interface{}) error {
 	err := this.parse()
 	if err != nil {
 		return err
@@ -364,14 +363,7 @@ func (this *validatorImplement) BindQuery(obj interface{}) error {
 	return MapToArray(this.query, obj, "validator")
 }
 
-func (this *validatorImplement) MustBindQuery(obj interface{}) {
-	err := this.BindQuery(obj)
-	if err != nil {
-		Throw(1, err.Error())
-	}
-}
-
-func (this *validatorImplement) BindForm(obj interface{}) error {
+func (this *validatorImplement) bindForm(obj interface{}) error {
 	err := this.parse()
 	if err != nil {
 		return err
@@ -382,14 +374,7 @@ func (this *validatorImplement) BindForm(obj interface{}) error {
 	return MapToArray(this.form, obj, "validator")
 }
 
-func (this *validatorImplement) MustBindForm(obj interface{}) {
-	err := this.BindForm(obj)
-	if err != nil {
-		Throw(1, err.Error())
-	}
-}
-
-func (this *validatorImplement) BindJson(obj interface{}) error {
+func (this *validatorImplement) bindJson(obj interface{}) error {
 	err := this.parse()
 	if err != nil {
 		return err
@@ -402,43 +387,100 @@ func (this *validatorImplement) BindJson(obj interface{}) error {
 	return json.Unmarshal(this.jsonForm, quickTagObj)
 }
 
-func (this *validatorImplement) MustBindJson(obj interface{}) {
-	err := this.BindJson(obj)
+func (this *validatorImplement) bindAndValidate(obj interface{}, bind func(obj interface{}) error) error {
+	//绑定以后按validate标签检查
+	err := bind(obj)
 	if err != nil {
-		Throw(1, err.Error())
+		return err
 	}
+	return this.Validate(obj)
 }
 
-func (this *validatorImplement) Bind(obj interface{}) error {
-	var err error
-	err = this.parse()
-	if err != nil {
-		return err
+func (this *validatorImplement) mustBind(err error) {
+	if err == nil {
+		return
 	}
-	err = this.BindParam(obj)
-	if err != nil {
-		return err
-	}
-	err = this.BindQuery(obj)
-	if err != nil {
-		return err
+	if exception, isOk := err.(*Exception); isOk {
+		panic(exception)
 	}
-	err = this.BindForm(obj)
-	if err != nil {
-		return err
-	}
-	err = this.BindJson(obj)
-	if err != nil {
-		return err
-	}
-	return nil
+	Throw(1, err.Error())
+}
+
+func (this *validatorImplement) BindParam(obj interface{}) error {
+	return this.bindAndValidate(obj, this.bindParam)
+}
+
+func (this *validatorImplement) MustBindParam(obj interface{}) {
+	this.mustBind(this.BindParam(obj))
+}
+
+func (this *validatorImplement) BindQuery(obj interface{}) error {
+	return this.bindAndValidate(obj, this.bindQuery)
+}
+
+func (this *validatorImplement) MustBindQuery(obj interface{}) {
+	this.mustBind(this.BindQuery(obj))
+}
+
+func (this *validatorImplement) BindForm(obj interface{}) error {
+	return this.bindAndValidate(obj, this.bindForm)
+}
+
+func (this *validatorImplement) MustBindForm(obj interface{}) {
+	this.mustBind(this.BindForm(obj))
+}
+
+func (this *validatorImplement) BindJson(obj interface{}) error {
+	return this.bindAndValidate(obj, this.bindJson)
+}
+
+func (this *validatorImplement) MustBindJson(obj interface{}) {
+	this.mustBind(this.BindJson(obj))
+}
+
+func (this *validatorImplement) Bind(obj interface{}) error {
+	//所有来源都绑定完成以后才检查，required的字段可以来自任意一个来源
+	return this.bindAndValidate(obj, func(obj interface{}) error {
+		var err error
+		err = this.parse()
+		if err != nil {
+			return err
+		}
+		err = this.bindParam(obj)
+		if err != nil {
+			return err
+		}
+		err = this.bindQuery(obj)
+		if err != nil {
+			return err
+		}
+		err = this.bindForm(obj)
+		if err != nil {
+			return err
+		}
+		err = this.bindJson(obj)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
 }
 
 func (this *validatorImplement) MustBind(obj interface{}) {
-	err := this.Bind(obj)
-	if err != nil {
-		Throw(1, err.Error())
+	this.mustBind(this.Bind(obj))
+}
+
+func (this *validatorImplement) Validate(obj interface{}) error {
+	//所有不通过的字段合并为一个异常，validate标签写错时直接panic
+	result := validateRule(obj)
+	if len(result) == 0 {
+		return nil
 	}
+	return NewException(this.config.ValidateCode, strings.Join(result, "; "))
+}
+
+func (this *validatorImplement) MustValidate(obj interface{}) {
+	this.mustBind(this.Validate(obj))
 }
 
 //元信息
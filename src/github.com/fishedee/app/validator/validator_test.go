@@ -2,6 +2,7 @@ package validator
 
 import (
 	. "github.com/fishedee/assert"
+	. "github.com/fishedee/language"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -311,3 +312,107 @@ func TestValidatorError(t *testing.T) {
 	AssertEqual(t, err != nil, true)
 
 }
+
+type ruleAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type RuleBase struct {
+	Page int `json:"page" validate:"omitempty,min=1"`
+}
+
+type ruleStruct struct {
+	RuleBase
+	Name      string        `json:"name" validate:"required,min=2,max=4"`
+	Email     string        `json:"email" validate:"omitempty,email"`
+	Type      string        `json:"type" validate:"oneof=a b"`
+	Code      string        `json:"code" validate:"omitempty,regexp=^[a-z]{20x2C3}$"`
+	Password  string        `json:"password"`
+	Password2 string        `json:"password2" validate:"eqfield=Password"`
+	Begin     int           `json:"begin"`
+	End       int           `json:"end" validate:"gtefield=Begin"`
+	Tag       []string      `json:"tag" validate:"max=2,dive,required,max=3"`
+	Address   *ruleAddress  `json:"address"`
+	History   []ruleAddress `json:"history" validate:"dive"`
+}
+
+func TestValidatorRule(t *testing.T) {
+	testCase := []struct {
+		contentType string
+		body        string
+		message     string
+	}{
+		{"application/json", `{"name":"fish","type":"a","tag":["ab"],"address":{"city":"gz"},"history":[{"city":"sz"}]}`, ""},
+		{"application/json", `{"name":"f","type":"c"}`, "name must be at least 2 characters; type must be one of [a b]"},
+		{"application/json", `{"name":"名字四个","type":"a","email":"a@b","code":"abcd"}`, "email must be a valid email; code format is invalid"},
+		{"application/json", `{"type":"b","password":"1","password2":"2","begin":3,"end":2}`, "name is required; password2 must be equal to password; end must be greater than or equal to begin"},
+		{"application/json", `{"name":"fish","type":"a","tag":["a","","abcd"]}`, "tag must be at most 2 items"},
+		{"application/json", `{"name":"fish","type":"a","tag":["","abcd"]}`, "tag[0] is required; tag[1] must be at most 3 characters"},
+		{"application/json", `{"name":"fish","type":"a","address":{},"history":[{"city":"sz"},{}]}`, "address.city is required; history[1].city is required"},
+		{"application/x-www-form-urlencoded", "name=fish&type=b&begin=1&end=1", ""},
+	}
+	for index, singleTestCase := range testCase {
+		r, _ := http.NewRequest("POST", "http://www.baidu.com/", strings.NewReader(singleTestCase.body))
+		r.Header.Set("Content-Type", singleTestCase.contentType)
+		validatorFactory, _ := NewValidatorFactory(ValidatorConfig{ValidateCode: 10001})
+		validator := validatorFactory.Create(r, nil)
+
+		var data ruleStruct
+		err := validator.Bind(&data)
+		if singleTestCase.message == "" {
+			AssertEqual(t, err, nil, index)
+			continue
+		}
+		exception, isOk := err.(*Exception)
+		AssertEqual(t, isOk, true, index)
+		AssertEqual(t, exception.GetCode(), 10001, index)
+		AssertEqual(t, exception.GetMessage(), singleTestCase.message, index)
+
+		//Must的版本直接抛出同一个异常
+		func() {
+			defer Catch(func(e Exception) {
+				AssertEqual(t, e.GetCode(), 10001, index)
+				AssertEqual(t, e.GetMessage(), singleTestCase.message, index)
+			})
+			validator.MustValidate(&data)
+			AssertEqual(t, true, false, index)
+		}()
+	}
+
+	//required的字段可以来自任意一个来源
+	r, _ := http.NewRequest("POST", "http://www.baidu.com/?type=a", strings.NewReader(`{"name":"fish"}`))
+	r.Header.Set("Content-Type", "application/json")
+	validatorFactory, _ := NewValidatorFactory(ValidatorConfig{})
+	var data ruleStruct
+	AssertEqual(t, validatorFactory.Create(r, nil).Bind(&data), nil)
+	AssertEqual(t, data.Name, "fish")
+	AssertEqual(t, data.Type, "a")
+
+	//匿名结构体的字段当作外层的字段
+	data = ruleStruct{RuleBase: RuleBase{Page: -1}, Name: "fish", Type: "a"}
+	err := validatorFactory.Create(r, nil).Validate(&data)
+	AssertEqual(t, err.(*Exception).GetMessage(), "page must be at least 1")
+
+	//规则写错属于代码的错误，直接panic，不会变成给用户看的异常
+	for index, invalid := range []interface{}{
+		&struct {
+			A int `validate:"min=a"`
+		}{A: 10},
+		&struct {
+			A int `validate:"unknown"`
+		}{},
+		&struct {
+			A int `validate:"eqfield=B"`
+		}{},
+	} {
+		func() {
+			defer func() {
+				p := recover()
+				AssertEqual(t, p != nil, true, index)
+				_, isOk := p.(*Exception)
+				AssertEqual(t, isOk, false, index)
+			}()
+			validatorFactory.Create(r, nil).MustValidate(invalid)
+		}()
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	. "github.com/fishedee/app/log"
 	. "github.com/fishedee/encoding"
 	"io"
 	"io/ioutil"
@@ -50,6 +52,7 @@ type Ajax struct {
 	DataType string
 	Data     interface{}
 	Cookie   interface{}
+	Context  context.Context
 
 	ResponseDataType string
 	ResponseData     interface{}
@@ -366,6 +369,15 @@ func (this *Ajax) createRequest() (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	//下游请求带上当前请求的ID
+	if this.Context != nil {
+		request = request.WithContext(this.Context)
+		requestId := GetRequestId(this.Context)
+		if requestId != "" && request.Header.Get("X-Request-Id") == "" {
+			request.Header.Set("X-Request-Id", requestId)
+		}
+	}
 	return request, nil
 }
 
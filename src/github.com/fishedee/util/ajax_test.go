@@ -3,6 +3,8 @@ package util
 import (
 	"compress/flate"
 	"compress/gzip"
+	"context"
+	. "github.com/fishedee/app/log"
 	. "github.com/fishedee/assert"
 	"net/http"
 	"net/http/httptest"
@@ -93,3 +95,29 @@ func GGTestAjaxProxy(t *testing.T) {
 	AssertEqual(t, err, nil)
 	AssertEqual(t, len(data) != 0, true)
 }
+
+func TestAjaxRequestId(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Request-Id")))
+	}))
+	defer server.Close()
+
+	testCase := []struct {
+		ctx       context.Context
+		requestId string
+	}{
+		{nil, ""},
+		{context.Background(), ""},
+		{WithRequestId(context.Background(), "request1"), "request1"},
+	}
+	for index, singleTestCase := range testCase {
+		var data string
+		err := DefaultAjaxPool.Get(&Ajax{
+			Url:          server.URL,
+			Context:      singleTestCase.ctx,
+			ResponseData: &data,
+		})
+		AssertEqual(t, err, nil, index)
+		AssertEqual(t, data, singleTestCase.requestId, index)
+	}
+}